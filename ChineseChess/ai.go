@@ -1,6 +1,7 @@
 package main
 
 import (
+	"log"
 	"sort"
 	"time"
 )
@@ -17,8 +18,21 @@ import (
 func (g *chessGame) ai() {
 	defer g.aiStatus.Store(aiPlay) // 设置状态,ai落子
 
-	g.distance = 0
 	g.aiPlayer = true
+	if m, ok := g.bookMove(); ok {
+		if g.showStats {
+			log.Printf("book move %s", m.iccs())
+		}
+		g.chessMove = m // 开局库中有这个局面,直接走棋
+		g.checkResign(g.evaluate())
+		return
+	}
+	g.checkResign(g.search())
+}
+
+// 为 g.aiPlayer 一方搜索走法,结果保存在 g.chessMove,返回 g.aiPlayer 一方的评估分数
+func (g *chessGame) search() int {
+	g.distance = 0
 	var (
 		ts       = time.Now()
 		i, value int
@@ -47,15 +61,36 @@ func (g *chessGame) ai() {
 	}
 
 	// 限定最大搜索深度,迭代加深会用历史表提高效率
+	g.stats.reset()
 	for i = 1; i <= limitMaxDepth; i++ {
 		value = g.searchFull(-mateValue, mateValue, i, false)
-		if time.Since(ts) > time.Second {
+
+		g.stats.finish(i, value)
+		if g.showStats {
+			log.Print(&g.stats)
+			st := g.stats // 复制一份给界面显示,避免数据竞争
+			g.lastStats.Store(&st)
+		}
+
+		if g.nodeLimit > 0 {
+			if g.stats.total() > g.nodeLimit {
+				break // 搜索节点数用完了,不受机器快慢影响,结果可以复现
+			}
+		} else if time.Since(ts) > time.Second {
 			break // 时间用完了,不再搜索
 		}
 		if value > winValue || value < -winValue {
 			break // 胜负已分,不用继续搜索
 		}
+		if g.skill != nil && i >= g.skill.depth {
+			break // 达到棋力等级限制的深度
+		}
+	}
+
+	if g.skill != nil {
+		g.skillMove(min(i, limitMaxDepth))
 	}
+	return value
 }
 
 const (
@@ -68,6 +103,7 @@ const (
 	advancedValue  = 3               // 先行权分值
 	limitMaxDepth  = 64              // 搜索最大深度
 	nullDepth      = 2               // 空步搜索多减去的搜索值
+	aiNodeLimit    = 100000          // 指定随机数种子时,每步搜索的节点数上限
 )
 
 /*
@@ -76,6 +112,7 @@ walk:
   false: 搜索红棋走法
 */
 func (g *chessGame) searchFull(vlAlpha, vlBeta, depth int, noNull bool) int {
+	g.stats.nodes++
 	mvHash := moveXY{x0: -1}
 	if g.distance > 0 {
 		// 1. 到达水平线,则调用静态搜索(注意: 由于空步裁剪,深度可能小于零)
@@ -87,6 +124,9 @@ func (g *chessGame) searchFull(vlAlpha, vlBeta, depth int, noNull bool) int {
 		if vlRep > 0 {
 			return g.repValue(vlRep)
 		}
+		if g.attackCount == 0 {
+			return g.drawValue() // 双方都没有进攻棋子,和棋
+		}
 
 		// 尝试置换表
 		vlRep = g.probeHash(vlAlpha, vlBeta, depth, &mvHash)
@@ -101,11 +141,13 @@ func (g *chessGame) searchFull(vlAlpha, vlBeta, depth int, noNull bool) int {
 
 		// 1-3. 尝试空步裁剪(根节点的Beta值是"MATE_VALUE"，所以不可能发生空步裁剪)
 		if !noNull && !g.inCheck() && g.nullOkay() {
+			g.stats.nullTries++
 			g.nullMove()
 			vlRep = -g.searchFull(-vlBeta, 1-vlBeta, depth-nullDepth-1, true)
 			g.undoNullMove()
 			if vlRep >= vlBeta && (g.nullSafe() ||
 				g.searchFull(vlAlpha, vlBeta, depth-nullDepth, true) >= vlBeta) {
+				g.stats.nullCuts++
 				return vlRep
 			}
 		}
@@ -118,13 +160,14 @@ func (g *chessGame) searchFull(vlAlpha, vlBeta, depth int, noNull bool) int {
 		mvBest   = moveXY{x0: -1}
 		v        moveXY
 		vl       int
+		sorter   moveSorter
 	)
 
-	if g.moveSort(mvHash) {
+	if g.moveSort(&sorter, mvHash) {
 		return g.mateValue() // 没棋了
 	}
 	for {
-		if v = g.next(); v.x0 < 0 {
+		if v = g.next(&sorter); v.x0 < 0 {
 			if v.x0 == -2 {
 				return g.mateValue() // 没棋了
 			}
@@ -152,6 +195,7 @@ func (g *chessGame) searchFull(vlAlpha, vlBeta, depth int, noNull bool) int {
 			if vl >= vlBeta {
 				hashFlag = hashBeta
 				mvBest = v
+				g.statsCut(v, mvHash)
 				break
 			}
 
@@ -205,81 +249,89 @@ const (
 	phaseRest     = 4
 )
 
-func (g *chessGame) moveSort(mvHash moveXY) bool {
-	g.mvs = g.mvs[:0]
-	g.vls = g.vls[:0]
-	g.mvHash.x0 = -1
-	g.mvKiller1.x0 = -1
-	g.mvKiller2.x0 = -1
-	g.phase = phaseHash
-	g.index = 0
-	g.singleReply = false
+// 走法排序器,每个搜索节点各自使用一个,避免递归搜索时互相覆盖
+type moveSorter struct {
+	mvHash, mvKiller1, mvKiller2 moveXY
+
+	mvs         []moveXY
+	vls         []int
+	phase       int
+	index       int
+	singleReply bool
+}
+
+func (g *chessGame) moveSort(s *moveSorter, mvHash moveXY) bool {
+	*s = moveSorter{
+		mvHash:    moveXY{x0: -1},
+		mvKiller1: moveXY{x0: -1},
+		mvKiller2: moveXY{x0: -1},
+	}
 
 	if g.inCheck() {
-		g.phase = phaseRest
+		s.phase = phaseRest
 
-		if g.canStep(g.aiPlayer, &g.mvs, nil) {
+		if g.canStep(g.aiPlayer, &s.mvs, nil) {
 			return true // 没棋了
 		}
-		for _, mv := range g.mvs {
+		for _, mv := range s.mvs {
 			// 要使用置换表启发,把置换表中的走法排在最前面
 			if mv == mvHash {
-				g.vls = append(g.vls, 0x7fffffff)
+				s.vls = append(s.vls, 0x7fffffff)
 			} else {
-				g.vls = append(g.vls, g.historyTable[historyIndex(mv)])
+				s.vls = append(s.vls, g.historyTable[historyIndex(mv)])
 			}
 		}
-		sort.Sort(&sortMoveXY{mvs: g.mvs, vls: g.vls})
-		g.singleReply = len(g.mvs) == 1 // 只有1个回棋
+		sort.Sort(&sortMoveXY{mvs: s.mvs, vls: s.vls})
+		s.singleReply = len(s.mvs) == 1 // 只有1个回棋
 	} else {
-		g.mvHash = mvHash
-		g.mvKiller1 = g.killerTable[g.distance][0]
-		g.mvKiller2 = g.killerTable[g.distance][1]
+		s.mvHash = mvHash
+		s.mvKiller1 = g.killerTable[g.distance][0]
+		s.mvKiller2 = g.killerTable[g.distance][1]
 	}
 	return false
 }
 
-func (g *chessGame) next() moveXY {
-	switch g.phase {
+func (g *chessGame) next(s *moveSorter) moveXY {
+	switch s.phase {
 	case phaseHash:
-		g.phase = phaseKiller1
-		if g.mvHash.x0 >= 0 {
-			return g.mvHash
+		s.phase = phaseKiller1
+		if s.mvHash.x0 >= 0 {
+			return s.mvHash
 		}
 		fallthrough
 	case phaseKiller1:
-		g.phase = phaseKiller2
-		if g.mvKiller1 != g.mvHash && g.mvKiller1.x0 >= 0 &&
-			g.canNext(g.mvKiller1.x0, g.mvKiller1.y0, g.mvKiller1.x1, g.mvKiller1.y1) {
-			return g.mvKiller1
+		s.phase = phaseKiller2
+		if s.mvKiller1 != s.mvHash && s.mvKiller1.x0 >= 0 &&
+			g.canNext(s.mvKiller1.x0, s.mvKiller1.y0, s.mvKiller1.x1, s.mvKiller1.y1) {
+			return s.mvKiller1
 		}
 		fallthrough
 	case phaseKiller2:
-		g.phase = phaseGenMoves
-		if g.mvKiller2 != g.mvHash && g.mvKiller2.x0 >= 0 &&
-			g.canNext(g.mvKiller2.x0, g.mvKiller2.y0, g.mvKiller2.x1, g.mvKiller2.y1) {
-			return g.mvKiller2
+		s.phase = phaseGenMoves
+		if s.mvKiller2 != s.mvHash && s.mvKiller2.x0 >= 0 &&
+			g.canNext(s.mvKiller2.x0, s.mvKiller2.y0, s.mvKiller2.x1, s.mvKiller2.y1) {
+			return s.mvKiller2
 		}
 		fallthrough
 	case phaseGenMoves:
-		g.phase = phaseRest
+		s.phase = phaseRest
 
-		g.mvs = g.mvs[:0]
-		if g.canStep(g.aiPlayer, &g.mvs, nil) {
+		s.mvs = s.mvs[:0]
+		if g.canStep(g.aiPlayer, &s.mvs, nil) {
 			return moveXY{x0: -2}
 		}
-		g.vls = g.vls[:0]
-		for _, mv := range g.mvs {
-			g.vls = append(g.vls, g.historyTable[historyIndex(mv)])
+		s.vls = s.vls[:0]
+		for _, mv := range s.mvs {
+			s.vls = append(s.vls, g.historyTable[historyIndex(mv)])
 		}
-		sort.Sort(&sortMoveXY{mvs: g.mvs, vls: g.vls})
-		g.index = 0
+		sort.Sort(&sortMoveXY{mvs: s.mvs, vls: s.vls})
+		s.index = 0
 		fallthrough
 	default:
-		for g.index < len(g.mvs) {
-			mv := g.mvs[g.index]
-			g.index++
-			if mv != g.mvHash && mv != g.mvKiller1 && mv != g.mvKiller2 {
+		for s.index < len(s.mvs) {
+			mv := s.mvs[s.index]
+			s.index++
+			if mv != s.mvHash && mv != s.mvKiller1 && mv != s.mvKiller2 {
 				return mv
 			}
 		}
@@ -287,6 +339,19 @@ func (g *chessGame) next() moveXY {
 	return moveXY{x0: -1}
 }
 
+// 统计产生 beta 截断的走法来源
+func (g *chessGame) statsCut(v, mvHash moveXY) {
+	g.stats.betaCuts++
+	switch kt := g.killerTable[g.distance]; v {
+	case mvHash:
+		g.stats.hashMvCuts++
+	case kt[0], kt[1]:
+		g.stats.killerCuts++
+	default:
+		g.stats.historyCuts++
+	}
+}
+
 func historyIndex(m moveXY) int {
 	// 根据走法,得到一个索引值,最大值为 0x99aa
 	return m.y0<<12 | m.y1<<8 | m.x0<<4 | m.x1
@@ -294,6 +359,7 @@ func historyIndex(m moveXY) int {
 
 // 静态(Quiescence)搜索
 func (g *chessGame) searchQuiesce(vlAlpha, vlBeta int) int {
+	g.stats.qNodes++
 	vl := g.mateValue()
 	if vl >= vlBeta {
 		return vl
@@ -303,6 +369,9 @@ func (g *chessGame) searchQuiesce(vlAlpha, vlBeta int) int {
 	if vlRep > 0 {
 		return g.repValue(vlRep)
 	}
+	if g.attackCount == 0 {
+		return g.drawValue()
+	}
 
 	if g.distance == limitMaxDepth {
 		return g.evaluate()
@@ -337,8 +406,9 @@ func (g *chessGame) searchQuiesce(vlAlpha, vlBeta int) int {
 		}
 
 		// 7. 如果局面评价没有截断，再生成吃子走法
+		// 没有吃子走法不代表没棋可走,困毙交给完全搜索判断,这里直接返回局面评价
 		if g.canStep(g.aiPlayer, &mvs, &vls) {
-			return g.mateValue() // 没棋了
+			return vlBest
 		}
 		// 根据vls排序,且vls也要进行排序
 		sort.Sort(&sortMoveXY{vls: vls, mvs: mvs})
@@ -438,12 +508,19 @@ func (g *chessGame) repValue(rep int) int {
 func (g *chessGame) getHashItem() *hashTable {
 	return g.hashTable[g.zobristKey&hashMask]
 }
-func (g *chessGame) probeHash(vlAlpha, vlBeta, depth int, mvHash *moveXY) int {
+func (g *chessGame) probeHash(vlAlpha, vlBeta, depth int, mvHash *moveXY) (vl int) {
+	g.stats.hashProbes++
 	hash := g.getHashItem()
 	if hash.zobristLock != g.zobristLock {
 		mvHash.x0 = -1
 		return -mateValue
 	}
+	g.stats.hashHits++
+	defer func() {
+		if vl > -mateValue {
+			g.stats.hashCuts++
+		}
+	}()
 
 	*mvHash = hash.mv
 	mate := false
@@ -523,9 +600,9 @@ func (g *chessGame) changeSide() {
 	g.zobristLock ^= PreGenZobristLockPlayer
 }
 func (g *chessGame) addPiece(x, y int, p uint8, del ...bool) {
-	pv := int(pieceValue[p][x][y])
+	pv, n := int(pieceValue[p][x][y]), 1
 	if len(del) > 0 && del[0] {
-		pv = -pv
+		pv, n = -pv, -1
 	}
 	// 仅更新分数,移动棋子交给调用方处理
 	if isRed(p) {
@@ -533,6 +610,9 @@ func (g *chessGame) addPiece(x, y int, p uint8, del ...bool) {
 	} else {
 		g.vlBlack += pv
 	}
+	if isAttacker(p) {
+		g.attackCount += n
+	}
 	g.zobristKey ^= PreGenZobristKeyTable[p][x][y]
 	g.zobristLock ^= PreGenZobristLockTable[p][x][y]
 }
@@ -0,0 +1,149 @@
+//go:build !nogui
+
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	animFrames = 18 // 走棋动画帧数,每秒60帧,约0.3秒
+	dragStart  = 4  // 鼠标按下后移动超过这个像素才算拖动,否则当作点击
+)
+
+// 走棋动画,棋盘数据已经是走完之后的局面,只是把 mv.x1,mv.y1 上的棋子从 [fx,fy] 滑到目标位置
+type moveAnim struct {
+	mv     moveXY
+	piece  uint8 // 移动的棋子
+	eaten  uint8 // 被吃掉的棋子,在目标位置逐渐消失
+	fx, fy float64
+	frame  int
+}
+
+// 正在拖动的棋子,[x,y]为棋子所在位置,[px,py]为鼠标按下的位置
+type dragPiece struct {
+	x, y   int
+	px, py int
+	moved  bool
+}
+
+// 棋盘位置对应的图片左上角坐标
+func squarePos(x, y int) (float64, float64) {
+	return float64(y*squareSize + topX), float64(x*squareSize + topY)
+}
+
+// 拖动中的棋子中心跟随鼠标
+func cursorPiecePos() (float64, float64) {
+	cx, cy := ebiten.CursorPosition()
+	return float64(cx - squareSize/2), float64(cy - squareSize/2)
+}
+
+// 走完一步后开始动画,拖动落子时从松开鼠标的位置滑到目标位置
+func (g *chessGame) startAnim(piece, eaten uint8) {
+	a := &moveAnim{mv: g.chessMove, piece: piece, eaten: eaten}
+	if d := g.drag; d != nil && d.moved && d.x == a.mv.x0 && d.y == a.mv.y0 {
+		a.fx, a.fy = cursorPiecePos()
+	} else {
+		a.fx, a.fy = squarePos(a.mv.x0, a.mv.y0)
+	}
+	g.anim = a
+}
+
+// 处理鼠标拖动,在 Update 中鼠标按下或松开时调用,点击走棋的逻辑不变
+func (g *chessGame) updateDrag(x, y int) (err error) {
+	inside := x >= 0 && x < boardX && y >= 0 && y < boardY
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if !inside {
+			return
+		}
+		if err = g.clickSquare(x, y); err != nil {
+			return
+		}
+		if qz := g.board[x][y]; qz > 0 && isRed(qz) == g.redPlayer && !g.gameOver {
+			// 按下己方棋子,准备拖动
+			px, py := ebiten.CursorPosition()
+			g.drag = &dragPiece{x: x, y: y, px: px, py: py}
+		}
+		return
+	}
+
+	d := g.drag
+	if d == nil {
+		return
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		if px, py := ebiten.CursorPosition(); abs(px, d.px) > dragStart || abs(py, d.py) > dragStart {
+			d.moved = true
+		}
+		return
+	}
+
+	// 松开鼠标,只是点击时已经在按下时选中棋子
+	if d.moved {
+		m := moveXY{x0: d.x, y0: d.y, x1: x, y1: y}
+		if inside && g.legalMove(m) {
+			err = g.clickSquare(x, y)
+		} else {
+			// 不合法的位置,棋子滑回原处
+			g.anim = &moveAnim{mv: moveXY{x0: d.x, y0: d.y, x1: d.x, y1: d.y}, piece: g.board[d.x][d.y]}
+			g.anim.fx, g.anim.fy = cursorPiecePos()
+		}
+	}
+	g.drag = nil
+	return
+}
+
+// 正在拖动或动画中的棋子不在原位置画,由 drawFloating 单独画在最上层
+func (g *chessGame) floating(x, y int) bool {
+	if d := g.drag; d != nil && d.moved && d.x == x && d.y == y {
+		return true
+	}
+	return g.anim != nil && g.anim.mv.x1 == x && g.anim.mv.y1 == y
+}
+
+func (g *chessGame) drawFloating(screen *ebiten.Image, board *chessBord) {
+	op := &ebiten.DrawImageOptions{}
+	if a := g.anim; a != nil && board[a.mv.x1][a.mv.y1] == a.piece {
+		t := float64(a.frame) / animFrames
+		tx, ty := squarePos(a.mv.x1, a.mv.y1)
+		if a.eaten > 0 {
+			// 被吃的棋子逐渐消失
+			op.GeoM.Translate(tx, ty)
+			op.ColorScale.ScaleAlpha(float32(1 - t))
+			screen.DrawImage(g.images[a.eaten], op)
+			op.ColorScale.Reset()
+		}
+
+		t = 1 - (1-t)*(1-t)*(1-t) // 先快后慢
+		op.GeoM.Reset()
+		op.GeoM.Translate(a.fx+(tx-a.fx)*t, a.fy+(ty-a.fy)*t)
+		screen.DrawImage(g.images[a.piece], op)
+		if g.chessMove.x1 == a.mv.x1 && g.chessMove.y1 == a.mv.y1 {
+			op.GeoM.Translate(0, -5)
+			screen.DrawImage(g.images[imgSelect], op)
+		}
+	}
+
+	if d := g.drag; d != nil && d.moved && board[d.x][d.y] > 0 {
+		op.GeoM.Reset()
+		op.GeoM.Translate(cursorPiecePos())
+		screen.DrawImage(g.images[board[d.x][d.y]], op)
+		op.GeoM.Translate(0, -5)
+		screen.DrawImage(g.images[imgSelect], op)
+	}
+}
+
+// 重新开局或恢复对局时,停止动画和拖动
+func (g *chessGame) stopAnim() {
+	g.anim, g.drag = nil, nil
+}
+
+// 每帧推进动画,在 Update 开头调用
+func (g *chessGame) updateAnim() {
+	if g.anim != nil {
+		if g.anim.frame++; g.anim.frame >= animFrames {
+			g.anim = nil
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+开局库,格式和象棋小巫师(xqwlight)的 book.dat 相同,每行一个走法:
+
+	lock,mv,weight[,win,draw,loss]
+
+lock 为局面校验码,mv 为走法,weight 为选择这个走法的权重,
+后面的胜和负局数是 ChineseChess book 命令生成开局库时附带的统计,加载时忽略
+
+校验码用象棋小巫师固定的 RC4 随机数生成,和 initZobrist 按种子生成的校验码无关,
+这样开局库可以在不同程序和不同种子之间通用
+走法用小巫师的 16x16 棋盘坐标表示,起点 + 终点*256
+*/
+
+type bookMove struct {
+	mv     moveXY
+	weight int
+}
+
+// 局面校验码对应的所有走法
+type openingBook map[uint32][]bookMove
+
+var (
+	bookLockPlayer uint32
+	bookLockTable  [pieceKinds * 2][256]uint32
+)
+
+// 象棋小巫师使用的 RC4 随机数生成器
+type rc4 struct {
+	s    [256]byte
+	x, y byte
+}
+
+func init() {
+	r := &rc4{}
+	for i := range r.s {
+		r.s[i] = byte(i)
+	}
+	var j byte
+	for i := range r.s {
+		j += r.s[i] // 密钥为[0]
+		r.s[i], r.s[j] = r.s[j], r.s[i]
+	}
+
+	// 小巫师依次生成 key,未使用的值,lock,这里只需要 lock
+	r.next()
+	r.next()
+	bookLockPlayer = r.next()
+	for i := range bookLockTable {
+		for j := range bookLockTable[i] {
+			r.next()
+			r.next()
+			bookLockTable[i][j] = r.next()
+		}
+	}
+}
+
+func (r *rc4) next() (n uint32) {
+	for i := 0; i < 4; i++ {
+		r.x++
+		r.y += r.s[r.x]
+		r.s[r.x], r.s[r.y] = r.s[r.y], r.s[r.x]
+		n |= uint32(r.s[r.s[r.x]+r.s[r.y]]) << (8 * i)
+	}
+	return
+}
+
+// 棋盘位置对应小巫师的坐标,mirror 为 true 时左右翻转
+func bookSquare(x, y int, mirror bool) int {
+	if mirror {
+		y = boardY - 1 - y
+	}
+	return (x+3)*16 + y + 3
+}
+
+// 计算局面在开局库中的校验码
+func bookLock(board *chessBord, red, mirror bool) (lock uint32) {
+	if !red {
+		lock = bookLockPlayer
+	}
+	for i := 0; i < boardX; i++ {
+		for j := 0; j < boardY; j++ {
+			if p := board[i][j]; p > 0 {
+				lock ^= bookLockTable[p-imgRedShuai][bookSquare(i, j, mirror)]
+			}
+		}
+	}
+	return
+}
+
+func bookEncode(m moveXY) int {
+	return bookSquare(m.x0, m.y0, false) + bookSquare(m.x1, m.y1, false)<<8
+}
+
+func bookDecode(mv int) (m moveXY, ok bool) {
+	m.x0, m.y0 = (mv&0xff)>>4-3, mv&0x0f-3
+	m.x1, m.y1 = (mv>>8&0xff)>>4-3, mv>>8&0x0f-3
+	for _, v := range [...]int{m.x0, m.x1} {
+		if v < 0 || v >= boardX {
+			return m, false
+		}
+	}
+	for _, v := range [...]int{m.y0, m.y1} {
+		if v < 0 || v >= boardY {
+			return m, false
+		}
+	}
+	return m, true
+}
+
+// 读取开局库,追加到 book 中,已有的局面会被新的走法替换
+// 这样可以用自己的开局库覆盖内置开局库中相同局面的走法
+func (book openingBook) load(r io.Reader) error {
+	var (
+		sc    = bufio.NewScanner(r)
+		line  int
+		added = make(map[uint32]bool)
+	)
+	for sc.Scan() {
+		line++
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || s[0] == '#' {
+			continue
+		}
+
+		f := strings.Split(s, ",")
+		if len(f) < 3 {
+			return fmt.Errorf("book line %d: want lock,mv,weight", line)
+		}
+		lock, err := strconv.ParseUint(f[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("book line %d: %w", line, err)
+		}
+		mv, err := strconv.Atoi(f[1])
+		if err != nil {
+			return fmt.Errorf("book line %d: %w", line, err)
+		}
+		weight, err := strconv.Atoi(f[2])
+		if err != nil {
+			return fmt.Errorf("book line %d: %w", line, err)
+		}
+		m, ok := bookDecode(mv)
+		if !ok {
+			return fmt.Errorf("book line %d: invalid move %d", line, mv)
+		}
+
+		if k := uint32(lock); !added[k] {
+			book[k], added[k] = nil, true
+		}
+		if weight > 0 {
+			book[uint32(lock)] = append(book[uint32(lock)], bookMove{mv: m, weight: weight})
+		}
+	}
+	return sc.Err()
+}
+
+// 按权重写出开局库,局面校验码从小到大排列,和 book.dat 一样
+func (book openingBook) write(w io.Writer, stats map[uint32]map[moveXY]*bookStats) error {
+	locks := make([]uint32, 0, len(book))
+	for k := range book {
+		locks = append(locks, k)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i] < locks[j] })
+
+	bw := bufio.NewWriter(w)
+	for _, k := range locks {
+		for _, m := range book[k] {
+			if st := stats[k][m.mv]; st != nil {
+				_, _ = fmt.Fprintf(bw, "%d,%d,%d,%d,%d,%d\n", k, bookEncode(m.mv), m.weight, st.win, st.draw, st.loss)
+			} else {
+				_, _ = fmt.Fprintf(bw, "%d,%d,%d\n", k, bookEncode(m.mv), m.weight)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// 从开局库中按权重随机选择当前局面的走法,没有找到时返回false
+func (g *chessGame) bookMove() (m moveXY, ok bool) {
+	var (
+		mirror bool
+		mvs    = g.book[bookLock(&g.board, !g.aiPlayer, false)]
+	)
+	if len(mvs) == 0 {
+		mirror = true // 左右对称的局面走法也对称
+		mvs = g.book[bookLock(&g.board, !g.aiPlayer, true)]
+	}
+
+	var (
+		legal []bookMove
+		total int
+	)
+	for _, v := range mvs {
+		if mirror {
+			v.mv.y0, v.mv.y1 = boardY-1-v.mv.y0, boardY-1-v.mv.y1
+		}
+		if p := g.board[v.mv.x0][v.mv.y0]; p > 0 && isRed(p) != g.aiPlayer && g.legalMove(v.mv) {
+			legal = append(legal, v)
+			total += v.weight
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	n := g.rand.Intn(total)
+	for _, v := range legal {
+		if n -= v.weight; n < 0 {
+			return v.mv, true
+		}
+	}
+	return
+}
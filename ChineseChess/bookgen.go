@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+根据对局记录生成开局库,不打开游戏窗口,用法:
+
+	ChineseChess book -dir games -ply 20 -min 2 -o club.dat
+
+对局记录为文本文件,和局域网对战协议类似,空行分隔多局,#开头为注释:
+
+	FEN <fen>           可选,默认为标准开局
+	MOVES <iccs ...>    对局走法,可以分成多行
+	RESULT <result>     对局结果,1-0 红胜,0-1 黑胜,1/2-1/2 和棋
+
+生成的开局库用 -book 参数加载
+*/
+
+// 开局库中一个走法的统计,胜和负都是对走这步棋的一方而言
+type bookStats struct {
+	count, win, draw, loss int
+}
+
+type gameRecord struct {
+	name   string // 文件名和第几局,用于错误信息
+	fen    string
+	moves  []string
+	result string
+}
+
+func makeBook(args []string) error {
+	set := flag.NewFlagSet("book", flag.ExitOnError)
+	fd := set.String("dir", ".", "directory of game records, searched recursively")
+	fp := set.Int("ply", 20, "only add the first N plies of each game to the book")
+	fm := set.Int("min", 2, "prune moves played in fewer than N games")
+	fo := set.String("o", "book.dat", "output book file, - means stdout")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		stats  = make(map[uint32]map[moveXY]*bookStats)
+		games  int
+		bad    int
+		walkFn = func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			//goland:noinspection GoUnhandledErrorResult
+			defer f.Close()
+
+			records, err := readGames(f, path)
+			if err != nil {
+				return err
+			}
+			for _, r := range records {
+				if err = r.addTo(stats, *fp); err != nil {
+					log.Printf("%s: %v, skipped", r.name, err)
+					bad++
+				} else {
+					games++
+				}
+			}
+			return nil
+		}
+	)
+	if err := filepath.WalkDir(*fd, walkFn); err != nil {
+		return err
+	}
+
+	// 去掉很少走的变着,权重为走这步棋的得分,每局胜2分和1分,从来没得过分的走法也去掉
+	book, moves := make(openingBook), 0
+	for lock, mvs := range stats {
+		for mv, st := range mvs {
+			if w := st.win*2 + st.draw; st.count >= *fm && w > 0 {
+				book[lock] = append(book[lock], bookMove{mv: mv, weight: w})
+			}
+		}
+		sort.Slice(book[lock], func(i, j int) bool { return book[lock][i].weight > book[lock][j].weight })
+		moves += len(book[lock])
+	}
+	log.Printf("%d games read, %d skipped, %d positions and %d moves in book", games, bad, len(book), moves)
+
+	if *fo == "-" {
+		return book.write(os.Stdout, stats)
+	}
+	fw, err := os.Create(*fo)
+	if err != nil {
+		return err
+	}
+	if err = book.write(fw, stats); err != nil {
+		_ = fw.Close()
+		return err
+	}
+	return fw.Close()
+}
+
+// 读取文件中的所有对局记录
+func readGames(r io.Reader, name string) (records []gameRecord, err error) {
+	var (
+		sc  = bufio.NewScanner(r)
+		cur = gameRecord{name: name + ": game 1"}
+		// 保存当前对局,开始下一局
+		next = func() {
+			if cur.fen != "" || len(cur.moves) > 0 {
+				records = append(records, cur)
+				cur = gameRecord{name: fmt.Sprintf("%s: game %d", name, len(records)+1)}
+			}
+		}
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			next()
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "FEN":
+			if len(cur.moves) > 0 {
+				next() // 没有空行分隔的下一局
+			}
+			cur.fen = arg
+		case "MOVES":
+			cur.moves = append(cur.moves, strings.Fields(arg)...)
+		case "RESULT":
+			cur.result = strings.TrimSpace(arg)
+		default:
+			return nil, fmt.Errorf("%s: unknown line %q", cur.name, line)
+		}
+	}
+	next()
+	return records, sc.Err()
+}
+
+// 重放对局,把前 ply 步每个局面走的棋加入统计
+func (r *gameRecord) addTo(stats map[uint32]map[moveXY]*bookStats, ply int) error {
+	if len(r.moves) == 0 {
+		return errors.New("no moves")
+	}
+
+	var redScore int // 红方得分,胜2和1负0
+	switch r.result {
+	case "1-0":
+		redScore = 2
+	case "1/2-1/2":
+		redScore = 1
+	case "0-1":
+	default:
+		return fmt.Errorf("invalid result %q, want 1-0, 0-1 or 1/2-1/2", r.result)
+	}
+
+	fen := r.fen
+	if fen == "" {
+		fen = boardStart
+	}
+	board, red, err := parseFEN(fen)
+	if err != nil {
+		return err
+	}
+
+	// 先检查所有走法都合法,再加入统计,跳过的对局不影响开局库
+	type played struct {
+		lock uint32
+		mv   moveXY
+		red  bool
+	}
+	var (
+		g     = &chessGame{board: board}
+		moves []played
+	)
+	for i, s := range r.moves {
+		if i >= ply {
+			break
+		}
+		m, ok := parseICCS(s)
+		if ok {
+			p := g.board[m.x0][m.y0]
+			ok = p > 0 && isRed(p) == red && g.legalMove(m)
+		}
+		if !ok {
+			return fmt.Errorf("illegal move %d %q", i+1, s)
+		}
+
+		moves = append(moves, played{lock: bookLock(&g.board, red, false), mv: m, red: red})
+		g.board[m.x1][m.y1] = g.board[m.x0][m.y0]
+		g.board[m.x0][m.y0] = 0
+		red = !red
+	}
+
+	for _, v := range moves {
+		if stats[v.lock] == nil {
+			stats[v.lock] = make(map[moveXY]*bookStats)
+		}
+		st := stats[v.lock][v.mv]
+		if st == nil {
+			st = new(bookStats)
+			stats[v.lock][v.mv] = st
+		}
+		st.count++
+		score := redScore
+		if !v.red {
+			score = 2 - redScore
+		}
+		switch score {
+		case 2:
+			st.win++
+		case 1:
+			st.draw++
+		default:
+			st.loss++
+		}
+	}
+	return nil
+}
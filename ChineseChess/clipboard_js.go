@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// 读取剪贴板文本,浏览器会请求用户授权
+func readClipboard() (string, error) {
+	cb := js.Global().Get("navigator").Get("clipboard")
+	if cb.IsUndefined() {
+		return "", errors.New("clipboard is not available")
+	}
+
+	type result struct {
+		text string
+		err  error
+	}
+	ch := make(chan result, 1) // 回调中不能阻塞
+	then := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ch <- result{text: args[0].String()}
+		return nil
+	})
+	defer then.Release()
+	catch := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ch <- result{err: errors.New(args[0].Call("toString").String())}
+		return nil
+	})
+	defer catch.Release()
+
+	cb.Call("readText").Call("then", then, catch)
+	r := <-ch
+	return r.text, r.err
+}
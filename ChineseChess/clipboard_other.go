@@ -0,0 +1,28 @@
+//go:build !windows && !js
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// 读取剪贴板文本,依次尝试 macOS,Wayland,X11 的剪贴板命令
+func readClipboard() (string, error) {
+	for _, cmd := range [][]string{
+		{"pbpaste"},
+		{"wl-paste", "--no-newline"},
+		{"xclip", "-out", "-selection", "clipboard"},
+		{"xsel", "--output", "--clipboard"},
+	} {
+		if _, err := exec.LookPath(cmd[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	}
+	return "", errors.New("no clipboard command found, install xclip, xsel or wl-clipboard")
+}
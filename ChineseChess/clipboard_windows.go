@@ -0,0 +1,51 @@
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32           = syscall.NewLazyDLL("user32.dll")
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	openClipboard    = user32.NewProc("OpenClipboard")
+	closeClipboard   = user32.NewProc("CloseClipboard")
+	getClipboardData = user32.NewProc("GetClipboardData")
+	globalLock       = kernel32.NewProc("GlobalLock")
+	globalUnlock     = kernel32.NewProc("GlobalUnlock")
+)
+
+const cfUnicodeText = 13 // 剪贴板 UTF-16 文本格式
+
+// 读取剪贴板文本
+func readClipboard() (string, error) {
+	// 打开和关闭剪贴板需要在同一个线程
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if r, _, err := openClipboard.Call(0); r == 0 {
+		return "", err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer closeClipboard.Call()
+
+	h, _, err := getClipboardData.Call(cfUnicodeText)
+	if h == 0 {
+		return "", err
+	}
+	p, _, err := globalLock.Call(h)
+	if p == 0 {
+		return "", err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer globalUnlock.Call(h)
+
+	// 以0结尾的 UTF-16 字符串
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&p))
+	n := 0
+	for *(*uint16)(unsafe.Add(ptr, n*2)) != 0 {
+		n++
+	}
+	return syscall.UTF16ToString(unsafe.Slice((*uint16)(ptr), n)), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/bitmapfont/v3"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// 棋盘四周留出显示坐标的宽度
+const diagramMargin = 20
+
+/*
+根据fen生成棋局图片,不打开游戏窗口,用法:
+
+	ChineseChess diagram -fen "3k5/9/9/9/9/9/9/9/9/4K4 w" -move e1e0 -o puzzle.png
+
+坐标和ICCS走法一致,横向从左到右为a-i,纵向从下到上为0-9
+*/
+func diagram(args []string) error {
+	set := flag.NewFlagSet("diagram", flag.ExitOnError)
+	ff := set.String("fen", boardStart, "position in FEN")
+	fm := set.String("move", "", "last move in ICCS, e.g. h2e2, marked on the diagram")
+	fo := set.String("o", "diagram.png", "output png file, - means stdout")
+	fk := set.String("skin", "", "skin directory or zip file replacing the built-in board and piece images")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	board, _, err := parseFEN(*ff)
+	if err != nil {
+		return fmt.Errorf("invalid fen %q: %w", *ff, err)
+	}
+
+	var last moveXY
+	if *fm != "" {
+		var ok bool
+		if last, ok = parseICCS(*fm); !ok {
+			return fmt.Errorf("invalid move %q", *fm)
+		}
+		if board[last.x0][last.y0] != 0 || board[last.x1][last.y1] == 0 {
+			// fen是走完这一步之后的局面,起点必须为空,终点必须有棋子
+			return fmt.Errorf("move %q does not match the position", *fm)
+		}
+	}
+
+	imgs, err := decodeImages(*fk)
+	if err != nil {
+		return err
+	}
+
+	bg := imgs[imgChessBoard].Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bg.Dx()+2*diagramMargin, bg.Dy()+2*diagramMargin))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, bg.Add(image.Pt(diagramMargin, diagramMargin)), imgs[imgChessBoard], bg.Min, draw.Over)
+
+	// 和界面一样,[topX,topY]是棋盘左上角起始点,每个棋子长宽squareSize
+	put := func(img image.Image, i, j, off int) {
+		pt := image.Pt(j*squareSize+topX+diagramMargin, i*squareSize+topY+off+diagramMargin)
+		draw.Draw(dst, img.Bounds().Sub(img.Bounds().Min).Add(pt), img, img.Bounds().Min, draw.Over)
+	}
+	for i := 0; i < boardX; i++ {
+		for j := 0; j < boardY; j++ {
+			if qz := board[i][j]; qz > 0 {
+				put(imgs[qz], i, j, 0)
+			}
+		}
+	}
+	if *fm != "" {
+		// 和界面一样圈出上一步的起点和终点
+		put(imgs[imgSelect], last.x0, last.y0, -5)
+		put(imgs[imgSelect], last.x1, last.y1, -5)
+	}
+
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(color.Black), Face: bitmapfont.FaceSC}
+	label := func(s string, x, y int) {
+		// x,y为文字中心位置
+		w := d.MeasureString(s).Round()
+		d.Dot = fixed.P(x-w/2, y+d.Face.Metrics().Ascent.Round()/2)
+		d.DrawString(s)
+	}
+	for j := 0; j < boardY; j++ {
+		x := j*squareSize + topX + squareSize/2 + diagramMargin
+		label(string(rune('a'+j)), x, diagramMargin/2)
+		label(string(rune('a'+j)), x, dst.Bounds().Dy()-diagramMargin/2)
+	}
+	for i := 0; i < boardX; i++ {
+		y := i*squareSize + topY + squareSize/2 + diagramMargin
+		label(string(rune('0'+boardX-1-i)), diagramMargin/2, y)
+		label(string(rune('0'+boardX-1-i)), dst.Bounds().Dx()-diagramMargin/2, y)
+	}
+
+	if *fo == "-" {
+		return png.Encode(os.Stdout, dst)
+	}
+	fw, err := os.Create(*fo)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(fw, dst); err != nil {
+		_ = fw.Close()
+		return err
+	}
+	return fw.Close()
+}
+
+// 从资源文件中解码棋盘和棋子图片,不依赖游戏窗口
+func decodeImages(skinName string) (imgs [imgLength]image.Image, err error) {
+	data := bytes.NewReader(resources)
+	zr, err := zip.NewReader(data, data.Size())
+	if err != nil {
+		return
+	}
+
+	var skin fs.FS
+	if skinName != "" {
+		var sc io.Closer
+		if skin, sc, err = openSkin(skinName); err != nil {
+			return
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer sc.Close()
+	}
+
+	for _, f := range zr.File {
+		i, ok := resNames[f.Name]
+		if !ok || filepath.Ext(f.Name) != ".png" {
+			continue
+		}
+
+		var (
+			fr       io.ReadCloser
+			fromSkin bool
+		)
+		if fr, fromSkin, err = openResource(skin, f); err != nil {
+			return
+		}
+		imgs[i], err = png.Decode(fr)
+		_ = fr.Close()
+		if err == nil && fromSkin {
+			err = checkSkinImage(f.Name, imgs[i].Bounds())
+		}
+		if err != nil {
+			if fromSkin {
+				err = fmt.Errorf("skin %s: %s: %w", skinName, f.Name, err)
+			}
+			return
+		}
+	}
+	return
+}
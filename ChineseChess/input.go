@@ -0,0 +1,86 @@
+//go:build !nogui
+
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/jan-bar/LittleGame/internal/ui"
+)
+
+// 输入fen的文本框,按F键打开,回车加载局面,Esc取消,Ctrl+V粘贴
+type fenBox struct {
+	text  string
+	err   string // 上次加载失败的原因
+	chars []rune
+	paste chan pasteResult // 后台读取剪贴板的结果
+}
+
+type pasteResult struct {
+	text string
+	err  error
+}
+
+func (g *chessGame) updateFENBox() {
+	b := g.fenBox
+	select {
+	case r := <-b.paste:
+		if r.err != nil {
+			b.err = r.err.Error()
+		} else {
+			b.text += strings.Join(strings.Fields(r.text), " ") // 去掉换行等多余空白
+		}
+	default:
+	}
+
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.fenBox = nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter), inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter):
+		if err := g.setStartFEN(strings.TrimSpace(b.text)); err != nil {
+			b.err = err.Error()
+			return
+		}
+		g.fenBox = nil
+		g.reset()
+		if g.net != nil {
+			g.netSync() // 联网对战只有主机能设置局面,同步给对方
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		if r := []rune(b.text); len(r) > 0 {
+			b.text = string(r[:len(r)-1])
+		}
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyV):
+		go func() {
+			s, err := readClipboard() // 读取剪贴板可能较慢,浏览器还需要用户授权
+			select {
+			case b.paste <- pasteResult{text: s, err: err}:
+			default: // 上次粘贴的结果还没处理,丢弃本次结果
+			}
+		}()
+	case !ctrl:
+		b.chars = ebiten.AppendInputChars(b.chars[:0])
+		b.text += string(b.chars)
+	}
+}
+
+// 在底部信息栏画出文本框,内容过长时只显示末尾部分,maxWidth 为可用宽度
+func (g *chessGame) drawFENBox(screen *ebiten.Image, maxWidth int) {
+	b, show := g.fenBox, g.fenBox.text
+	for len(show) > 0 && int(text.Advance(lang[msgFENPrompt]+show+"_", ui.Face)) > maxWidth {
+		_, n := utf8.DecodeRuneInString(show)
+		show = show[n:]
+	}
+	ui.DrawText(screen, lang[msgFENPrompt]+show+"_", 5, boardHeight-20)
+
+	if b.err != "" {
+		ui.DrawText(screen, b.err, 5, boardHeight-40)
+	} else {
+		ui.DrawText(screen, lang[msgFENHelp], 5, boardHeight-40)
+	}
+}
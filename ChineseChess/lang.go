@@ -0,0 +1,120 @@
+package main
+
+import "github.com/jan-bar/LittleGame/internal/locale"
+
+// 界面文字编号,对应 langText 中的翻译
+const (
+	msgTitle          = iota // 窗口标题
+	msgRedWin                // 红方胜
+	msgBlackWin              // 黑方胜
+	msgDraw                  // 和棋
+	msgRedLongCheck          // 红方长将判负
+	msgBlackLongCheck        // 黑方长将判负
+	msgRedStalemate          // 红方困毙判负
+	msgBlackStalemate        // 黑方困毙判负
+	msgClickRestart          // 点击重新开始
+	msgWaitRestart           // 等待主机重新开始
+	msgAIOff                 // 人人对战
+	msgAIOn                  // 人机对战
+	msgAIThink               // 电脑思考中
+	msgNetRed                // 联网执红
+	msgNetBlack              // 联网执黑
+	msgNetWaiting            // 等待对手连接
+	msgNetConnecting         // 正在连接主机
+	msgNetYourTurn           // 轮到本方
+	msgNetOpponent           // 轮到对方
+	msgFENPrompt             // fen输入框提示
+	msgFENHelp               // fen输入框操作说明
+	msgViewing               // 查看历史局面
+	msgRedResign             // 红方认输
+	msgBlackResign           // 黑方认输
+	msgDrawAgreed            // 同意和棋
+	msgRedDrawOffer          // 红方提和
+	msgBlackDrawOffer        // 黑方提和
+	msgDrawOffered           // 已经提和,等待对方答复
+	msgDrawDeclined          // 对方拒绝和棋
+	msgDrawMaterial          // 双方都没有进攻棋子,和棋
+	msgResume                // 询问是否继续上次的对局
+	msgLength                // 文字总数
+)
+
+var (
+	langText = map[string]*[msgLength]string{
+		"en": {
+			msgTitle:          "Chinese Chess",
+			msgRedWin:         "Red Win",
+			msgBlackWin:       "Black Win",
+			msgDraw:           "a draw in chess",
+			msgRedLongCheck:   "long will be negative, Black Win",
+			msgBlackLongCheck: "long will be negative, Red Win",
+			msgRedStalemate:   "Red has no legal move, Black Win",
+			msgBlackStalemate: "Black has no legal move, Red Win",
+			msgClickRestart:   " Click Mouse To Restart",
+			msgWaitRestart:    " Wait Host To Restart",
+			msgAIOff:          "AI OFF  Space: Mode/Restart  F: FEN  M: Moves  R: Resign  D: Draw",
+			msgAIOn:           "AI ON   Space: Mode/Restart  F: FEN  M: Moves  R: Resign  D: Draw",
+			msgAIThink:        "AI THINK Please Wait",
+			msgNetRed:         "NET RED  ",
+			msgNetBlack:       "NET BLACK",
+			msgNetWaiting:     " Waiting For Opponent",
+			msgNetConnecting:  " Connecting To Host",
+			msgNetYourTurn:    " Your Turn",
+			msgNetOpponent:    " Opponent's Turn",
+			msgFENPrompt:      "FEN> ",
+			msgFENHelp:        "Enter: Load  Esc: Cancel  Ctrl+V: Paste",
+			msgViewing:        "VIEW %d/%d  Left/Right: Step  Esc: Back",
+			msgRedResign:      "Red resigns, Black Win",
+			msgBlackResign:    "Black resigns, Red Win",
+			msgDrawAgreed:     "Draw by agreement",
+			msgRedDrawOffer:   "Red offers a draw  Y: Accept  N: Decline",
+			msgBlackDrawOffer: "Black offers a draw  Y: Accept  N: Decline",
+			msgDrawOffered:    "Draw offered, waiting for reply",
+			msgDrawDeclined:   "Draw offer declined",
+			msgDrawMaterial:   "Insufficient material, a draw",
+			msgResume:         "Resume the unfinished game of %d moves?  Y: Resume  N: New game",
+		},
+		"zh": {
+			msgTitle:          "中国象棋",
+			msgRedWin:         "红方胜",
+			msgBlackWin:       "黑方胜",
+			msgDraw:           "双方长将,和棋",
+			msgRedLongCheck:   "红方长将判负,黑方胜",
+			msgBlackLongCheck: "黑方长将判负,红方胜",
+			msgRedStalemate:   "红方困毙,黑方胜",
+			msgBlackStalemate: "黑方困毙,红方胜",
+			msgClickRestart:   ",点击鼠标重新开始",
+			msgWaitRestart:    ",等待主机重新开始",
+			msgAIOff:          "人人对战  空格切换/重开  F局面  M棋谱  R认输  D提和",
+			msgAIOn:           "人机对战  空格切换/重开  F局面  M棋谱  R认输  D提和",
+			msgAIThink:        "电脑思考中,请稍候",
+			msgNetRed:         "联网执红",
+			msgNetBlack:       "联网执黑",
+			msgNetWaiting:     "  等待对手连接",
+			msgNetConnecting:  "  正在连接主机",
+			msgNetYourTurn:    "  轮到你走棋",
+			msgNetOpponent:    "  等待对方走棋",
+			msgFENPrompt:      "局面> ",
+			msgFENHelp:        "回车加载  Esc取消  Ctrl+V粘贴",
+			msgViewing:        "查看第%d/%d步  左右键切换  Esc返回",
+			msgRedResign:      "红方认输,黑方胜",
+			msgBlackResign:    "黑方认输,红方胜",
+			msgDrawAgreed:     "双方同意和棋",
+			msgRedDrawOffer:   "红方提和  Y键同意  N键拒绝",
+			msgBlackDrawOffer: "黑方提和  Y键同意  N键拒绝",
+			msgDrawOffered:    "已提和,等待对方答复",
+			msgDrawDeclined:   "对方拒绝和棋",
+			msgDrawMaterial:   "双方都无进攻棋子,和棋",
+			msgResume:         "继续上次走了%d步的对局?  Y键继续  N键新开一局",
+		},
+	}
+	// 当前使用的语言
+	lang = langText["en"]
+	// 当前使用的棋谱记法
+	notation = notationTexts["en"]
+)
+
+// 设置界面语言和棋谱记法,name为空时根据系统区域设置选择
+func setLang(name string) {
+	l := locale.Pick(name)
+	lang, notation = langText[l], notationTexts[l]
+}
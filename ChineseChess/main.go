@@ -2,12 +2,8 @@ package main
 
 import (
 	"log"
+	"math/rand"
 	"sync/atomic"
-
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/audio"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
 /*
@@ -15,23 +11,23 @@ import (
 https://github.com/Capricornwqh/ChineseChess
 */
 
-func main() {
-	game := &chessGame{
-		hashTable:    make(map[uint32]*hashTable, hashMask+1),
-		historyTable: make(map[int]int, 8000),
-		killerTable:  make(map[int]*[2]moveXY, limitMaxDepth),
+// 子命令只生成文件,不打开游戏窗口,返回false表示不是子命令
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
 	}
-	err := game.loadResources()
-	if err != nil {
-		log.Fatal(err)
+	cmd := map[string]func([]string) error{
+		"diagram":  diagram,
+		"book":     makeBook,
+		"selfplay": selfPlay,
+	}[args[0]]
+	if cmd == nil {
+		return false
 	}
-	game.reset() // 开局
-
-	ebiten.SetWindowSize(boardWidth, boardHeight)
-	ebiten.SetWindowTitle("中国象棋")
-	if err = ebiten.RunGame(game); err != nil {
+	if err := cmd(args[1:]); err != nil {
 		log.Fatal(err)
 	}
+	return true
 }
 
 //goland:noinspection SpellCheckingInspection
@@ -47,8 +43,7 @@ type (
 		zobristLock uint32 // 校验码
 	}
 	chessGame struct {
-		images [imgLength]*ebiten.Image   // 所需图片资源
-		audios [musicLength]*audio.Player // 所需音频资源
+		gameUI // 界面使用的图片,声音和输入状态,不带界面的版本中为空
 
 		// 棋盘数据
 		board, copy chessBord
@@ -57,12 +52,16 @@ type (
 		aiStatus atomic.Uint32
 		vlRed    int // 红棋分数
 		vlBlack  int // 黑棋分数
-		distance int // 搜索深度
+		// 双方车马炮兵的数量,为0时双方都无法取胜
+		attackCount int
+		distance    int // 搜索深度
 
 		mvList  []moveXY // 存放每次走法的数组
 		pcList  []uint8  // 存放每步被吃的棋子,如果没有棋子被吃,存放的是0
 		keyList []uint32 // 存放zobristKey
 		chkList []bool   // 是否被将军
+		record  []moveXY // 对局走法记录,只在主线程使用,不受 ai 搜索影响
+		notes   []string // 对局走法的棋谱记法,和 record 一一对应
 
 		zobristKey  uint32 // 棋面局势校验码
 		zobristLock uint32 // 唯一性校验码
@@ -73,18 +72,52 @@ type (
 		// 杀手走法表
 		killerTable map[int]*[2]moveXY
 
-		mvHash, mvKiller1, mvKiller2 moveXY
-
-		mvs         []moveXY
-		vls         []int
-		phase       int
-		index       int
-		singleReply bool
+		// 开局局面,以及解析后的棋盘和是否红方先行
+		startFEN   string
+		startBoard chessBord
+		startRed   bool
+		// 随机数种子,每局开始时用它重置 rand,相同种子和走法可以复现对局
+		seed int64
+		rand *rand.Rand
+		// 大于0时按搜索节点数限制 ai 思考
+		nodeLimit int
+		// 棋力等级,为nil时使用完整棋力
+		skill *skillLevel
+		// ai 上一步搜索的评估分数,连续评估低于 -resign 的步数,以及是否认输
+		aiValue  int
+		aiLosing int
+		aiResign bool
+		resign   int
+		// 搜索统计信息,showStats 为true时每层输出日志并在界面显示 lastStats
+		stats     searchStats
+		showStats bool
+		lastStats atomic.Pointer[searchStats]
+		// 开局库,以及额外加载的开局库文件和是否只用这个文件
+		book     openingBook
+		bookFile string
+		bookOnly bool
+		// 皮肤包目录或者zip文件,为空时只用内置资源
+		skin string
+		// 局域网对战,为nil时表示本地对局
+		net *netPlayer
+		// 启动时找到的上次没有下完的对局,为nil时表示不用询问是否继续
+		resume *savedGame
+		// 最后一次保存的内容,以及距离下次检查保存的帧数
+		saved    string
+		saveTick int
+		// 棋谱面板,以及查看的历史局面,view<0 时表示当前对局
+		showMoves   bool
+		panelScroll int
+		view        int
+		viewBoard   chessBord
 
 		// 是否游戏结束
 		gameOver bool
-		// 显示提示信息
-		showMsg string
+		// 显示提示信息,对应 langText 中的文字编号
+		showMsg int
+		// 收到的提和,以及对局中的临时提示,走棋后清除,都对应 langText 中的文字编号
+		drawOffer int
+		notice    int
 
 		// [x0,y0]上一步位置,[x1,y1]当前落子位置
 		chessMove moveXY
@@ -94,107 +127,6 @@ type (
 	}
 )
 
-func (g *chessGame) Layout(_, _ int) (int, int) {
-	return boardWidth, boardHeight
-}
-
-func (g *chessGame) Update() (err error) {
-	switch g.aiStatus.Load() {
-	case aiThink:
-		return // ai 正在思考,忽略其他任何操作
-	case aiPlay:
-		if !g.gameOver { // 游戏没结束,黑棋落子
-			if err = g.clickSquare(g.chessMove.x1, g.chessMove.y1); err != nil {
-				return
-			}
-		}
-		g.aiStatus.Store(aiOn)
-		return // ai模拟黑棋落子,恢复状态
-	}
-
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-		if g.chessMove.x0 == -1 {
-			// 在初始化时,按空格键切换 ai对战 / 人人对战
-			if !g.aiStatus.CompareAndSwap(aiOff, aiOn) {
-				g.aiStatus.Store(aiOff)
-			}
-		} // else {} 玩到中途按空格只会重新开始,不切换模式
-		g.reset()
-		return
-	}
-
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		if g.gameOver {
-			g.reset()
-		} else {
-			x, y := ebiten.CursorPosition()
-			// 鼠标坐标转换为g.board[x][y],判断合法则进行走棋逻辑
-			x, y = (y-topY)/squareSize, (x-topX)/squareSize
-			if x >= 0 && x < boardX && y >= 0 && y < boardY {
-				if err = g.clickSquare(x, y); err != nil {
-					return
-				}
-			}
-		}
-	}
-	return
-}
-
-func (g *chessGame) Draw(screen *ebiten.Image) {
-	aiStatus, board := g.aiStatus.Load(), g.board
-	if aiStatus == aiThink {
-		board = g.copy // ai 思考时,画界面用 g.copy, g.board 会用于计算
-	}
-
-	op := &ebiten.DrawImageOptions{}
-	screen.DrawImage(g.images[imgChessBoard], op)
-
-	var (
-		i, j int
-
-		geoMReset = func(i, j, off int) {
-			op.GeoM.Reset()
-			// 图像向右是X,向下是Y,但是数组向下是i,向右是j
-			// [8,13]是棋盘左上角起始点,每个棋子长宽squareSize
-			xp, yp := float64(j*squareSize+topX), float64(i*squareSize+topY+off)
-			op.GeoM.Translate(xp, yp)
-		}
-	)
-	for i = 0; i < boardX; i++ {
-		for j = 0; j < boardY; j++ {
-			if qz := board[i][j]; qz > 0 {
-				geoMReset(i, j, 0)
-				screen.DrawImage(g.images[qz], op)
-
-				if g.chessMove.x1 == i && g.chessMove.y1 == j {
-					// 棋子被选中,在相对偏移-5位置画圆圈
-					op.GeoM.Translate(0, -5)
-					screen.DrawImage(g.images[imgSelect], op)
-				}
-			} else if g.chessMove.x0 == i && g.chessMove.y0 == j {
-				// 该棋子上次所在位置,圈起来,提示该棋子从哪里走
-				geoMReset(i, j, -5)
-				screen.DrawImage(g.images[imgSelect], op)
-			}
-		}
-	}
-
-	var show string
-	if g.gameOver {
-		show = g.showMsg + " Click Mouse To Restart"
-	} else {
-		switch aiStatus {
-		case aiOff:
-			show = "AI OFF   Key Space To Switch And Restart"
-		case aiOn:
-			show = "AI ON    Key Space To Switch And Restart"
-		case aiThink:
-			show = "AI THINK Please Wait"
-		}
-	}
-	ebitenutil.DebugPrintAt(screen, show, 5, boardHeight-20)
-}
-
 func (g *chessGame) clickSquare(x, y int) (err error) {
 	if qz := g.board[x][y]; qz > 0 {
 		if isRed(qz) == g.redPlayer {
@@ -221,6 +153,14 @@ func (g *chessGame) clickSquare(x, y int) (err error) {
 
 func isRed(p uint8) bool { return p >= imgRedShuai && p <= imgRedBing }
 
+// 车马炮兵可以过河进攻,只剩帅仕相时双方都无法将死对方
+func isAttacker(p uint8) bool {
+	if !isRed(p) {
+		p -= pieceKinds
+	}
+	return p >= imgRedMa && p <= imgRedBing
+}
+
 /*
 walk
   true:  表示黑棋走子
@@ -329,20 +269,17 @@ func (g *chessGame) isJiang(attack bool) bool {
 	}
 	return false
 }
-func (g *chessGame) playAudio(music int) (err error) {
-	if music >= musicSelect && music <= musicGameLose {
-		p := g.audios[music]
-		if err = p.Rewind(); err != nil {
-			return
-		}
-		p.Play()
-	}
-	return
-}
 
 func (g *chessGame) reset() {
-	g.vlRed, g.vlBlack = 0, 0
-	g.loadFEN(boardStart)
+	g.vlRed, g.vlBlack, g.attackCount = 0, 0, 0
+	g.board, g.redPlayer = g.startBoard, g.startRed
+	for i := 0; i < boardX; i++ {
+		for j := 0; j < boardY; j++ {
+			if p := g.board[i][j]; p > 0 {
+				g.addPiece(i, j, p)
+			}
+		}
+	}
 
 	g.zobristKey = 0
 	g.zobristLock = 0
@@ -352,9 +289,33 @@ func (g *chessGame) reset() {
 	g.keyList = make([]uint32, 1, 64)
 	g.chkList = make([]bool, 1, 64)
 	g.chkList[0] = g.isJiang(!g.redPlayer) // 己方被将军
+	g.record, g.notes = g.record[:0], g.notes[:0]
+	g.view, g.panelScroll = -1, 0
+	g.rand = rand.New(rand.NewSource(g.seed))
 
 	g.gameOver = false
 	g.chessMove.x0, g.chessMove.x1 = -1, -1
+	g.stopAnim()
+	g.drawOffer, g.notice = 0, 0
+	g.aiValue, g.aiLosing, g.aiResign = g.vlBlack-g.vlRed, 0, false
+
+	// 开局局面走棋方就已经无棋可走,被将死或困毙
+	if g.canStep(!g.redPlayer, nil, nil) {
+		switch {
+		case g.chkList[0] && g.redPlayer:
+			g.showMsg = msgBlackWin
+		case g.chkList[0]:
+			g.showMsg = msgRedWin
+		case g.redPlayer:
+			g.showMsg = msgRedStalemate
+		default:
+			g.showMsg = msgBlackStalemate
+		}
+		g.endGame()
+	} else if g.attackCount == 0 {
+		g.showMsg = msgDrawMaterial
+		g.endGame()
+	}
 }
 
 func (g *chessGame) stepNext(x, y, music int) (err error) {
@@ -362,19 +323,29 @@ func (g *chessGame) stepNext(x, y, music int) (err error) {
 		return // 初始未选中
 	}
 
-	if g.canNext(g.chessMove.x0, g.chessMove.y0, x, y) {
+	if m := (moveXY{x0: g.chessMove.x0, y0: g.chessMove.y0, x1: x, y1: y}); g.legalMove(m) {
+		g.notes = append(g.notes, g.moveName(m)) // 走之前计算棋谱记法
+		g.scrollTo(len(g.notes) - 1)
 		qz0, qz1 := g.board[x][y], g.board[g.chessMove.x0][g.chessMove.y0]
-		g.board[x][y] = qz1 // 尝试走这一步
+		g.board[x][y] = qz1 // 走这一步
 		g.board[g.chessMove.x0][g.chessMove.y0] = 0
 
-		if g.isJiang(!g.redPlayer) {
-			g.board[x][y], g.board[g.chessMove.x0][g.chessMove.y0] = qz0, qz1
-			return // 走这一步己方被将军,不能走,恢复局势
-		}
-
 		g.chessMove.x1, g.chessMove.y1 = x, y
 		g.aiPlayer = !g.redPlayer
 		g.makeMove(g.chessMove, qz1, qz0) // 更新分数
+		g.record = append(g.record, g.chessMove)
+		g.startAnim(qz1, qz0)
+		g.drawOffer, g.notice = 0, 0 // 走棋等于拒绝提和
+
+		if g.net != nil && g.net.myTurn(g.redPlayer) && !g.net.replay {
+			// 本方走棋通知对方,结束对局时也通知对方
+			g.net.send("MOVE " + g.chessMove.iccs())
+			defer func() {
+				if g.gameOver {
+					g.netEnd()
+				}
+			}()
+		}
 
 		if err = g.playAudio(music); err != nil {
 			return
@@ -385,54 +356,75 @@ func (g *chessGame) stepNext(x, y, music int) (err error) {
 			if g.canStep(g.redPlayer, nil, nil) {
 				playMusic := musicGameWin
 				if g.redPlayer {
-					g.showMsg = "Red Win"
+					g.showMsg = msgRedWin
 				} else {
 					if g.aiStatus.Load() > aiOff {
 						// ai模式黑棋赢了,播放失败音乐
 						playMusic = musicGameLose
 					}
-					g.showMsg = "Black Win"
+					g.showMsg = msgBlackWin
 				}
 				err = g.playAudio(playMusic)
-				g.gameOver = true
+				g.endGame()
 				return // 赢了直接返回
 			}
 			// 没有赢,因此只播放一下将军
 			if err = g.playAudio(musicJiang); err != nil {
 				return
 			}
+		} else if g.canStep(g.redPlayer, nil, nil) {
+			// 敌方没有被将军,但已经无棋可走,困毙判负
+			if g.redPlayer {
+				g.showMsg = msgBlackStalemate
+			} else {
+				g.showMsg = msgRedStalemate
+			}
+			err = g.playAudio(musicStalemate)
+			g.endGame()
+			return
+		}
+
+		if g.attackCount == 0 {
+			// 双方都只剩帅仕相,谁也无法将死对方
+			g.showMsg = msgDrawMaterial
+			g.endGame()
+			return
 		}
 
 		if vlRep := g.repStatus(3); vlRep > 0 {
 			switch vlRep = g.repValue(vlRep); {
 			case vlRep > -winValue && vlRep < winValue:
-				g.showMsg = "a draw in chess" // 双方都在长将,和棋
+				g.showMsg = msgDraw // 双方都在长将,和棋
 			case g.redPlayer != (vlRep < 0):
-				g.showMsg = "long will be negative, Black Win" // 红棋长将
+				g.showMsg = msgRedLongCheck // 红棋长将
 				err = g.playAudio(musicGameWin)
 			default:
-				g.showMsg = "long will be negative, Red Win" // 黑棋长将
+				g.showMsg = msgBlackLongCheck // 黑棋长将
 				err = g.playAudio(musicGameWin)
 			}
-			g.gameOver = true
+			g.endGame()
 			return
 		}
 
-		if g.redPlayer && g.aiStatus.Load() == aiOn {
-			for x = 0; x < boardX; x++ {
-				for y = 0; y < boardY; y++ {
-					// ai 思考时,界面用 g.copy 渲染
-					g.copy[x][y] = g.board[x][y]
-				}
-			}
-			g.aiStatus.Store(aiThink)
-			go g.ai() // 设置状态,ai思考中,并启动 ai 协程
-		}
 		g.redPlayer = !g.redPlayer
 	}
 	return
 }
 
+// 判断走法是否合法: 符合棋子走法规则,且走完这一步己方不被将军
+func (g *chessGame) legalMove(m moveXY) bool {
+	if !g.canNext(m.x0, m.y0, m.x1, m.y1) {
+		return false
+	}
+
+	qz0, qz1 := g.board[m.x0][m.y0], g.board[m.x1][m.y1]
+	g.board[m.x1][m.y1] = qz0 // 尝试走这一步
+	g.board[m.x0][m.y0] = 0
+	jiang := g.isJiang(!isRed(qz0))
+	g.board[m.x1][m.y1], g.board[m.x0][m.y0] = qz1, qz0 // 恢复局势
+	return !jiang
+}
+
 func (g *chessGame) canNext(x0, y0, x1, y1 int) bool {
 	if x0 == x1 && y0 == y1 {
 		return false // 起止点不能是同一个
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+)
+
+const (
+	panelWidth  = 200 // 棋谱面板宽度,显示在棋盘右侧
+	panelTop    = 8   // 第一行棋谱的位置
+	panelRow    = 18  // 每行棋谱的高度
+	panelRows   = (boardHeight - panelTop*2) / panelRow
+	panelRedX   = 44  // 红方走法的位置
+	panelBlackX = 120 // 黑方走法的位置
+)
+
+// 棋谱记法,中文使用"炮二平五"这种记法,英文使用WXF记法"C2.5"
+type notationText struct {
+	pieces     [2][pieceKinds]string // 红方和黑方的棋子名称
+	redNum     [10]string            // 红方的纵线和步数,从红方右边数起
+	blackNum   [10]string            // 黑方的纵线和步数,从黑方右边数起
+	forward    string                // 进
+	backward   string                // 退
+	traverse   string                // 平
+	order      [][]string            // 同一纵线上有2-5个相同棋子时,从前到后的名称
+	orderFirst bool                  // 前后名称写在棋子名称之前
+}
+
+var notationTexts = map[string]*notationText{
+	"en": {
+		pieces:   [2][pieceKinds]string{{"K", "A", "E", "H", "R", "C", "P"}, {"K", "A", "E", "H", "R", "C", "P"}},
+		redNum:   [10]string{"", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		blackNum: [10]string{"", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		forward:  "+",
+		backward: "-",
+		traverse: ".",
+		order:    [][]string{{"+", "-"}, {"1", "2", "3"}, {"1", "2", "3", "4"}, {"1", "2", "3", "4", "5"}},
+	},
+	"zh": {
+		pieces:     [2][pieceKinds]string{{"帅", "仕", "相", "马", "车", "炮", "兵"}, {"将", "士", "象", "马", "车", "炮", "卒"}},
+		redNum:     [10]string{"", "一", "二", "三", "四", "五", "六", "七", "八", "九"},
+		blackNum:   [10]string{"", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		forward:    "进",
+		backward:   "退",
+		traverse:   "平",
+		order:      [][]string{{"前", "后"}, {"前", "中", "后"}, {"前", "二", "三", "后"}, {"前", "二", "三", "四", "后"}},
+		orderFirst: true,
+	},
+}
+
+// 走法m的棋谱记法,需要在走这一步之前调用
+func (g *chessGame) moveName(m moveXY) string {
+	p := g.board[m.x0][m.y0]
+	red := isRed(p)
+	side, kind := 0, p-imgRedShuai
+	if !red {
+		side, kind = 1, p-imgBlackJiang
+	}
+	n := notation
+	num := func(v int) string {
+		if red {
+			return n.redNum[v]
+		}
+		return n.blackNum[v]
+	}
+	file := func(y int) int {
+		if red {
+			return boardY - y // 红方从右到左为一到九
+		}
+		return y + 1 // 黑方从右到左为1到9,也就是屏幕上从左到右
+	}
+
+	// 同一纵线上相同的棋子,按从前到后排列,红方前面是x较小的一边
+	var same []int
+	for x := 0; x < boardX; x++ {
+		if g.board[x][m.y0] == p {
+			same = append(same, x)
+		}
+	}
+	if !red {
+		slices.Reverse(same)
+	}
+
+	s := n.pieces[side][kind] + num(file(m.y0))
+	if len(same) > 1 && len(same)-2 < len(n.order) {
+		o := n.order[len(same)-2][slices.Index(same, m.x0)]
+		if n.orderFirst {
+			s = o + n.pieces[side][kind]
+		} else {
+			s = n.pieces[side][kind] + o
+		}
+	}
+
+	dir, to := n.traverse, file(m.y1)
+	if m.x1 != m.x0 {
+		if (m.x1 < m.x0) == red {
+			dir = n.forward
+		} else {
+			dir = n.backward
+		}
+		if kind == 0 || kind >= imgRedJu-imgRedShuai {
+			to = abs(m.x1, m.x0) // 帅车炮兵直走时写步数,马相仕写到达的纵线
+		}
+	}
+	return s + dir + num(to)
+}
+
+// 棋谱中第i步所在的行和列,开局黑方先走时第一行红方位置为空
+func (g *chessGame) moveSlot(i int) (row, col int) {
+	if !g.startRed {
+		i++
+	}
+	return i / 2, i % 2
+}
+
+// 让第i步显示在棋谱面板可见范围内
+func (g *chessGame) scrollTo(i int) {
+	row, _ := g.moveSlot(i)
+	if row < g.panelScroll {
+		g.panelScroll = row
+	} else if row >= g.panelScroll+panelRows {
+		g.panelScroll = row - panelRows + 1
+	}
+}
+
+// 查看走完前k步的历史局面,k<0或者已经是当前局面时回到对局
+func (g *chessGame) setView(k int) {
+	if k < 0 || k >= len(g.record) {
+		g.view = -1
+		if len(g.record) > 0 {
+			g.scrollTo(len(g.record) - 1)
+		}
+		return
+	}
+
+	g.view, g.viewBoard = k, g.startBoard
+	for _, m := range g.record[:k] {
+		g.viewBoard[m.x1][m.y1] = g.viewBoard[m.x0][m.y0]
+		g.viewBoard[m.x0][m.y0] = 0
+	}
+	if k > 0 {
+		g.scrollTo(k - 1)
+	}
+}
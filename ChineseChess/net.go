@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+局域网对战协议,每条消息为一行文本,使用空格分隔命令和参数
+
+	FEN <fen>          主机->客户端: 开局局面,客户端收到后重新开局
+	MOVES [iccs ...]   主机->客户端: 紧跟在FEN之后,当前对局已经走过的全部走法,用于断线重连恢复对局
+	MOVE <iccs>        双方: 本方走了一步棋,ICCS坐标格式,例如: MOVE h2e2
+	END <reason>       双方: 对局结束及原因,reason 为 langText 中的文字编号,双方界面语言可以不同
+	                   认输和同意和棋也用这条消息通知对方
+	DRAW offer|decline 双方: 提和,以及拒绝对方的提和
+
+主机执红,客户端执黑,主机是对局状态的权威方,每次连接成功都会发送 FEN+MOVES 同步对局
+双方收到走法都会用 canNext 和己方被将军判断校验,非法走法会断开连接,重连后由主机重新同步
+*/
+
+const (
+	netConnected = "CONNECTED" // 内部消息: 连接成功
+	netClosed    = "CLOSED"    // 内部消息: 连接断开
+
+	netRetry   = 2 * time.Second // 客户端断线重连间隔
+	netTimeout = 3 * time.Second // 发送消息超时时间
+)
+
+// END 消息中的结束原因和 langText 文字编号的对应关系,
+// 协议中只用这些名称,不用文字编号,增删界面文字不会改变协议
+var netEndReasons = map[string]int{
+	"red-mate":        msgRedWin,
+	"black-mate":      msgBlackWin,
+	"perpetual-draw":  msgDraw,
+	"red-perpetual":   msgRedLongCheck,
+	"black-perpetual": msgBlackLongCheck,
+	"red-stalemate":   msgRedStalemate,
+	"black-stalemate": msgBlackStalemate,
+	"red-resign":      msgRedResign,
+	"black-resign":    msgBlackResign,
+	"draw-agreed":     msgDrawAgreed,
+	"draw-material":   msgDrawMaterial,
+}
+
+type netPlayer struct {
+	host   bool   // true: 主机执红,false: 客户端执黑
+	addr   string // 监听或连接地址
+	online bool   // 是否已连接,只在主线程使用
+	replay bool   // 正在恢复对局,恢复的走法不用再发给对方
+
+	mu   sync.Mutex
+	conn net.Conn
+
+	// 网络协程收到的消息,在 Update 中由主线程处理,确保棋盘数据线程安全
+	recv chan string
+}
+
+func newNetPlayer(host bool, addr string) (*netPlayer, error) {
+	n := &netPlayer{host: host, addr: addr, recv: make(chan string, 64)}
+	if host {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("waiting for opponent on %q...", ln.Addr())
+		go n.accept(ln)
+	} else {
+		go n.dial()
+	}
+	return n, nil
+}
+
+// 主机持续等待连接,同时只允许1个对手,对手断线后可以重新连接
+func (n *netPlayer) accept(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		n.mu.Lock()
+		busy := n.conn != nil
+		if !busy {
+			n.conn = conn
+		}
+		n.mu.Unlock()
+		if busy {
+			_ = conn.Close() // 已经有对手了
+			continue
+		}
+
+		go n.read(conn)
+	}
+}
+
+// 客户端连接主机,断线后自动重连
+func (n *netPlayer) dial() {
+	for {
+		conn, err := net.DialTimeout("tcp", n.addr, netTimeout)
+		if err != nil {
+			time.Sleep(netRetry)
+			continue
+		}
+
+		n.mu.Lock()
+		n.conn = conn
+		n.mu.Unlock()
+
+		n.read(conn)
+		time.Sleep(netRetry)
+	}
+}
+
+func (n *netPlayer) read(conn net.Conn) {
+	log.Printf("connected to %q", conn.RemoteAddr())
+	n.recv <- netConnected
+
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			n.recv <- line
+		}
+	}
+
+	log.Printf("disconnected from %q", conn.RemoteAddr())
+	_ = conn.Close()
+	n.recv <- netClosed // 先通知断线,再允许新的连接,确保消息顺序
+
+	n.mu.Lock()
+	if n.conn == conn {
+		n.conn = nil
+	}
+	n.mu.Unlock()
+}
+
+// 发送一条消息,未连接时直接丢弃,重连后由主机同步对局
+func (n *netPlayer) send(msg string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return
+	}
+
+	_ = n.conn.SetWriteDeadline(time.Now().Add(netTimeout))
+	if _, err := n.conn.Write([]byte(msg + "\n")); err != nil {
+		log.Println(err)
+		_ = n.conn.Close() // 读协程会收到错误并通知断线
+	}
+}
+
+func (n *netPlayer) close() {
+	n.mu.Lock()
+	if n.conn != nil {
+		_ = n.conn.Close()
+	}
+	n.mu.Unlock()
+}
+
+// 轮到本方走棋
+func (n *netPlayer) myTurn(redPlayer bool) bool {
+	return n.online && n.host == redPlayer
+}
+
+func (n *netPlayer) status(redPlayer bool) string {
+	side := lang[msgNetBlack]
+	if n.host {
+		side = lang[msgNetRed]
+	}
+
+	switch {
+	case !n.online && n.host:
+		return side + lang[msgNetWaiting]
+	case !n.online:
+		return side + lang[msgNetConnecting]
+	case n.myTurn(redPlayer):
+		return side + lang[msgNetYourTurn]
+	default:
+		return side + lang[msgNetOpponent]
+	}
+}
+
+// 主线程处理网络消息
+func (g *chessGame) netUpdate() error {
+	for {
+		select {
+		case msg := <-g.net.recv:
+			if err := g.netHandle(msg); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (g *chessGame) netHandle(msg string) (err error) {
+	cmd, arg, _ := strings.Cut(msg, " ")
+	switch cmd {
+	case netConnected:
+		g.net.online = true
+		if g.net.host {
+			g.netSync() // 新连接或重连,同步当前对局
+		}
+	case netClosed:
+		g.net.online = false
+	case "FEN":
+		if !g.net.host {
+			if err = g.setStartFEN(arg); err != nil {
+				log.Printf("invalid fen %q: %v, reconnect to resync", arg, err)
+				g.net.close()
+				return nil
+			}
+			g.reset()
+		}
+	case "MOVES":
+		if !g.net.host {
+			g.net.replay = true
+			for _, mv := range strings.Fields(arg) {
+				if err = g.netMove(mv, false); err != nil {
+					break
+				}
+			}
+			g.net.replay = false
+		}
+	case "MOVE":
+		err = g.netMove(arg, true)
+	case "END":
+		if id, ok := netEndReasons[arg]; ok && !g.gameOver {
+			g.showMsg = id
+			g.endGame()
+		} else if !ok {
+			log.Printf("unknown end reason %q", arg)
+		}
+	case "DRAW":
+		if g.gameOver {
+			break
+		}
+		if arg == "offer" {
+			g.drawOffer = msgRedDrawOffer // 对方提和
+			if g.net.host {
+				g.drawOffer = msgBlackDrawOffer
+			}
+		} else {
+			g.notice = msgDrawDeclined
+		}
+	default:
+		log.Printf("unknown message %q", msg)
+	}
+	return
+}
+
+// 通知对方对局结束,结束原因为 g.showMsg
+func (g *chessGame) netEnd() {
+	for k, id := range netEndReasons {
+		if id == g.showMsg {
+			g.net.send("END " + k)
+			return
+		}
+	}
+	log.Printf("no end reason for %q", langText["en"][g.showMsg])
+}
+
+// 发送当前对局开局局面和全部走法
+func (g *chessGame) netSync() {
+	mvs := make([]string, 0, len(g.record)+1)
+	mvs = append(mvs, "MOVES")
+	for _, mv := range g.record {
+		mvs = append(mvs, mv.iccs())
+	}
+	g.net.send("FEN " + g.startFEN)
+	g.net.send(strings.Join(mvs, " "))
+}
+
+// 执行对方发来的走法,remote 为 true 时只能是对方的走法
+func (g *chessGame) netMove(s string, remote bool) error {
+	m, ok := parseICCS(s)
+	if ok && !g.gameOver && (!remote || !g.net.myTurn(g.redPlayer)) {
+		qz := g.board[m.x0][m.y0]
+		ok = qz > 0 && isRed(qz) == g.redPlayer && g.legalMove(m)
+	} else {
+		ok = false
+	}
+	if !ok {
+		log.Printf("illegal move %q, reconnect to resync", s)
+		g.net.close()
+		return nil
+	}
+
+	g.chessMove.x0, g.chessMove.y0 = m.x0, m.y0
+	return g.clickSquare(m.x1, m.y1)
+}
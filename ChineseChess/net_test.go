@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// 红方已经走了炮二平五,轮到黑方
+const netTestFEN = "rnbakabnr/9/1c5c1/p1p1p1p1p/9/9/P1P1P1P1P/1C2C4/9/RNBAKABNR b - - 1 1"
+
+func init() {
+	initZobrist(1)
+}
+
+func newNetTestGame(t *testing.T, host bool) *chessGame {
+	t.Helper()
+	g := &chessGame{seed: 1, net: &netPlayer{host: host, recv: make(chan string, 64)}}
+	if err := g.setStartFEN(boardStart); err != nil {
+		t.Fatal(err)
+	}
+	g.reset()
+	return g
+}
+
+// 用 net.Pipe 连接主机和客户端,和 accept,dial 一样启动读协程
+func connectNetTest(t *testing.T, host, client *chessGame) {
+	t.Helper()
+	hc, cc := net.Pipe()
+	host.net.conn, client.net.conn = hc, cc
+	go host.net.read(hc)
+	go client.net.read(cc)
+	t.Cleanup(func() {
+		_ = hc.Close()
+		_ = cc.Close()
+	})
+
+	waitNet(t, host, func() bool { return host.net.online })
+	waitNet(t, client, func() bool { return client.net.online && client.startFEN == host.startFEN })
+}
+
+// 在主线程处理收到的网络消息,直到 done 返回true
+func waitNet(t *testing.T, g *chessGame, done func() bool) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for !done() {
+		select {
+		case msg := <-g.net.recv:
+			if err := g.netHandle(msg); err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for network messages")
+		}
+	}
+}
+
+// 本方走一步棋,和鼠标先点棋子再点目标位置一样
+func playNetTest(t *testing.T, g *chessGame, iccs string) {
+	t.Helper()
+	m, ok := parseICCS(iccs)
+	if !ok {
+		t.Fatalf("invalid move %q", iccs)
+	}
+	n := len(g.record)
+	g.chessMove.x0, g.chessMove.y0 = m.x0, m.y0
+	if err := g.clickSquare(m.x1, m.y1); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.record) != n+1 {
+		t.Fatalf("move %q not played", iccs)
+	}
+}
+
+func TestNetSync(t *testing.T) {
+	host, client := newNetTestGame(t, true), newNetTestGame(t, false)
+	if err := host.setStartFEN(netTestFEN); err != nil {
+		t.Fatal(err)
+	}
+	host.reset()
+	connectNetTest(t, host, client)
+	if client.board != host.board || client.redPlayer {
+		t.Fatal("client did not load the host position")
+	}
+
+	playNetTest(t, client, "h9g7")
+	waitNet(t, host, func() bool { return len(host.record) == 1 })
+	playNetTest(t, host, "h0g2")
+	waitNet(t, client, func() bool { return len(client.record) == 2 })
+	if client.board != host.board || client.redPlayer != host.redPlayer {
+		t.Fatal("boards differ after the moves")
+	}
+
+	// 重连的客户端从 FEN 和 MOVES 恢复对局
+	again := newNetTestGame(t, false)
+	host.net.online = false
+	connectNetTest(t, host, again)
+	waitNet(t, again, func() bool { return len(again.record) == 2 })
+	if again.board != host.board || again.redPlayer {
+		t.Fatal("reconnected client did not restore the game")
+	}
+}
+
+func TestNetIllegalMove(t *testing.T) {
+	host, client := newNetTestGame(t, true), newNetTestGame(t, false)
+	connectNetTest(t, host, client)
+
+	// 轮到红方走棋时客户端走了红方的车,主机拒绝并断开连接,重连后再同步
+	board := host.board
+	client.net.send("MOVE a0a1")
+	waitNet(t, host, func() bool { return !host.net.online })
+	if host.board != board || len(host.record) != 0 || host.gameOver {
+		t.Fatal("illegal move changed the host game")
+	}
+}
+
+func TestNetDraw(t *testing.T) {
+	host, client := newNetTestGame(t, true), newNetTestGame(t, false)
+	connectNetTest(t, host, client)
+
+	client.net.send("DRAW offer")
+	waitNet(t, host, func() bool { return host.drawOffer == msgBlackDrawOffer })
+	host.net.send("DRAW decline")
+	waitNet(t, client, func() bool { return client.notice == msgDrawDeclined })
+
+	host.net.send("DRAW offer")
+	waitNet(t, client, func() bool { return client.drawOffer == msgRedDrawOffer })
+	client.showMsg = msgDrawAgreed
+	client.endGame()
+	client.netEnd()
+	waitNet(t, host, func() bool { return host.gameOver })
+	if host.showMsg != msgDrawAgreed {
+		t.Fatalf("host ended with %q", langText["en"][host.showMsg])
+	}
+}
+
+func TestNetEnd(t *testing.T) {
+	host, client := newNetTestGame(t, true), newNetTestGame(t, false)
+	connectNetTest(t, host, client)
+
+	// 不认识的结束原因被忽略
+	client.net.send("END 23")
+	client.net.send("END black-resign")
+	waitNet(t, host, func() bool { return host.gameOver })
+	if host.showMsg != msgBlackResign {
+		t.Fatalf("host ended with %q", langText["en"][host.showMsg])
+	}
+
+	// 走棋将死对方时同时发送结束原因,双方结果一致
+	if err := host.setStartFEN("4k4/1R7/R8/9/9/9/9/9/9/3K5 w - - 0 1"); err != nil {
+		t.Fatal(err)
+	}
+	host.reset()
+	host.netSync()
+	waitNet(t, client, func() bool { return client.startFEN == host.startFEN && !client.gameOver })
+	playNetTest(t, host, "a7a9")
+	waitNet(t, client, func() bool { return client.gameOver })
+	if host.showMsg != msgRedWin || client.showMsg != msgRedWin {
+		t.Fatalf("host %q, client %q", langText["en"][host.showMsg], langText["en"][client.showMsg])
+	}
+}
@@ -0,0 +1,26 @@
+//go:build nogui
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// 不带界面的版本,不依赖 ebiten,可以在没有显示器的机器上运行 diagram 和 book 子命令
+// go build -tags nogui -o chess-tools
+func main() {
+	if !runCommand(os.Args[1:]) {
+		fmt.Fprintln(os.Stderr, "usage: chess-tools diagram|book|selfplay [flags]")
+		os.Exit(2)
+	}
+}
+
+// 界面版本才有的数据,这里为空
+type gameUI struct{}
+
+func (g *chessGame) playAudio(int) error { return nil }
+
+func (g *chessGame) startAnim(_, _ uint8) {}
+
+func (g *chessGame) stopAnim() {}
@@ -1,102 +1,45 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
 	_ "embed"
-	"image/png"
-	"io"
-	"path/filepath"
-
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/audio"
-	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+	"errors"
+	"fmt"
+	"strings"
 )
 
 //go:embed resources.zip
 var resources []byte
 
-func (g *chessGame) loadResources() error {
-	var (
-		data     = bytes.NewReader(resources)
-		audioCtx = audio.NewContext(48000)
-	)
-
-	zr, err := zip.NewReader(data, data.Size())
-	if err != nil {
-		return err
-	}
-
-	//goland:noinspection SpellCheckingInspection 文件名和资源对应关系
-	resMap := map[string]uint8{
-		"ChessBoard.png": imgChessBoard,
-		"Select.png":     imgSelect,
-		"RedShuai.png":   imgRedShuai,
-		"RedShi.png":     imgRedShi,
-		"RedXiang.png":   imgRedXiang,
-		"RedMa.png":      imgRedMa,
-		"RedJu.png":      imgRedJu,
-		"RedPao.png":     imgRedPao,
-		"RedBing.png":    imgRedBing,
-		"BlackJiang.png": imgBlackJiang,
-		"BlackShi.png":   imgBlackShi,
-		"BlackXiang.png": imgBlackXiang,
-		"BlackMa.png":    imgBlackMa,
-		"BlackJu.png":    imgBlackJu,
-		"BlackPao.png":   imgBlackPao,
-		"BlackBing.png":  imgBlackBing,
-		"Select.wav":     musicSelect,
-		"Put.wav":        musicPut,
-		"Eat.wav":        musicEat,
-		"Jiang.wav":      musicJiang,
-		"GameWin.wav":    musicGameWin,
-		"GameLose.wav":   musicGameLose,
-		"book.dat":       0,
-	}
-	for _, f := range zr.File {
-		i, ok := resMap[f.Name]
-		if !ok {
-			continue
-		}
-
-		err = func() error {
-			fr, err := f.Open()
-			if err != nil {
-				return err
-			}
-			//goland:noinspection GoUnhandledErrorResult
-			defer fr.Close()
-
-			switch filepath.Ext(f.Name) {
-			case ".png":
-				img, err := png.Decode(fr)
-				if err != nil {
-					return err
-				}
-				g.images[i] = ebiten.NewImageFromImage(img)
-			case ".wav":
-				wr, err := wav.DecodeWithSampleRate(audioCtx.SampleRate(), fr)
-				if err != nil {
-					return err
-				}
-				wd, err := io.ReadAll(wr)
-				if err != nil {
-					return err
-				}
-				g.audios[i] = audioCtx.NewPlayerFromBytes(wd)
-			case ".dat":
-				// todo 开局库解析
-			}
-			return nil
-		}()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+//goland:noinspection SpellCheckingInspection
+var resNames = map[string]uint8{ // 文件名和资源对应关系
+	"ChessBoard.png": imgChessBoard,
+	"Select.png":     imgSelect,
+	"RedShuai.png":   imgRedShuai,
+	"RedShi.png":     imgRedShi,
+	"RedXiang.png":   imgRedXiang,
+	"RedMa.png":      imgRedMa,
+	"RedJu.png":      imgRedJu,
+	"RedPao.png":     imgRedPao,
+	"RedBing.png":    imgRedBing,
+	"BlackJiang.png": imgBlackJiang,
+	"BlackShi.png":   imgBlackShi,
+	"BlackXiang.png": imgBlackXiang,
+	"BlackMa.png":    imgBlackMa,
+	"BlackJu.png":    imgBlackJu,
+	"BlackPao.png":   imgBlackPao,
+	"BlackBing.png":  imgBlackBing,
+	"Select.wav":     musicSelect,
+	"Put.wav":        musicPut,
+	"Eat.wav":        musicEat,
+	"Jiang.wav":      musicJiang,
+	"GameWin.wav":    musicGameWin,
+	"GameLose.wav":   musicGameLose,
+	"Stalemate.wav":  musicStalemate, // 本项目合成的两声下降音,440到330Hz再到196Hz,按 MIT 许可证随本仓库发布
+	"book.dat":       0,
 }
 
-func (g *chessGame) loadFEN(fen string) {
+// 解析并校验fen,返回棋盘和是否轮到红方走棋
+func parseFEN(fen string) (board chessBord, redPlayer bool, err error) {
 	// fen介绍: https://www.xqbase.com/protocol/cchess_fen.htm
 	// king,advisor,bishop,knight,rook,cannon,pawn
 	// 红: 帅-K,仕-A,相-B,马-N,车-R,炮-C,兵-P
@@ -104,65 +47,154 @@ func (g *chessGame) loadFEN(fen string) {
 	// 数字代表空位数量,"w"代表红方走,"b"代表黑方走,两个"-"在中国象棋中没有意义
 	// 表示双方没有吃子的走棋步数(半回合数),通常该值达到120就要判和(六十回合自然限着),一旦形成局面的上一步是吃子,这里就标记"0"
 	// 最后一个数字表示回合数, 示例请看: boardStart
-	// 本逻辑只确保解析不会报错,没有判断棋局是否正确
+	// 只使用前两项,省略走棋方时默认红方先行
+
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return board, false, errors.New("empty fen")
+	}
+
+	redPlayer = true // 默认红棋先行
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w", "r":
+		case "b":
+			redPlayer = false
+		default:
+			return board, false, fmt.Errorf("invalid side to move %q, want w or b", fields[1])
+		}
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != boardX {
+		return board, false, fmt.Errorf("fen has %d ranks, want %d", len(ranks), boardX)
+	}
 
 	var (
-		i, j int
-		p    uint8
+		p     uint8
+		count [imgLength]int // 每种棋子的数量
 	)
-	g.redPlayer = true // 默认红棋先行
-	for is := 0; is < len(fen); is++ {
-		p = 0
-
-		switch fen[is] {
-		case ' ':
-			if is++; is < len(fen) && fen[is] == 'b' {
-				g.redPlayer = false // 只有这种情况轮到黑棋
+	for i, rank := range ranks {
+		j := 0
+		for k := 0; k < len(rank); k++ {
+			p = 0
+			switch c := rank[k]; c {
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				j += int(c - '0') // 跳过空位
+			case 'K':
+				p = imgRedShuai
+			case 'k':
+				p = imgBlackJiang
+			case 'A':
+				p = imgRedShi
+			case 'a':
+				p = imgBlackShi
+			case 'B':
+				p = imgRedXiang
+			case 'b':
+				p = imgBlackXiang
+			case 'N':
+				p = imgRedMa
+			case 'n':
+				p = imgBlackMa
+			case 'R':
+				p = imgRedJu
+			case 'r':
+				p = imgBlackJu
+			case 'C':
+				p = imgRedPao
+			case 'c':
+				p = imgBlackPao
+			case 'P':
+				p = imgRedBing
+			case 'p':
+				p = imgBlackBing
+			default:
+				return board, false, fmt.Errorf("rank %d: unknown piece %q", boardX-1-i, c)
 			}
-			return
-		case '/':
-			i++ // 换行,复位该行所有数据
-			for j = boardY; j > 0; j-- {
-				g.board[i][j-1] = 0
+
+			if p > 0 {
+				if j < boardY {
+					if !pieceSquare(p, i, j) {
+						return board, false, fmt.Errorf("%s on illegal square %c%d",
+							pieceName(p), 'a'+j, boardX-1-i)
+					}
+					board[i][j] = p
+					count[p]++
+				}
+				j++
 			}
-		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			j += int(fen[is] - '0') // 跳过空行
-		case 'K':
-			p = imgRedShuai
-		case 'k':
-			p = imgBlackJiang
-		case 'A':
-			p = imgRedShi
-		case 'a':
-			p = imgBlackShi
-		case 'B':
-			p = imgRedXiang
-		case 'b':
-			p = imgBlackXiang
-		case 'N':
-			p = imgRedMa
-		case 'n':
-			p = imgBlackMa
-		case 'R':
-			p = imgRedJu
-		case 'r':
-			p = imgBlackJu
-		case 'C':
-			p = imgRedPao
-		case 'c':
-			p = imgBlackPao
-		case 'P':
-			p = imgRedBing
-		case 'p':
-			p = imgBlackBing
 		}
+		if j != boardY {
+			return board, false, fmt.Errorf("rank %d has %d files, want %d", boardX-1-i, j, boardY)
+		}
+	}
+
+	for p = imgRedShuai; p <= imgBlackBing; p++ {
+		limit := pieceLimit[(p-imgRedShuai)%pieceKinds]
+		if count[p] > limit {
+			return board, false, fmt.Errorf("too many %ss: %d, at most %d", pieceName(p), count[p], limit)
+		}
+	}
+	if count[imgRedShuai] == 0 {
+		return board, false, errors.New("missing red king")
+	}
+	if count[imgBlackJiang] == 0 {
+		return board, false, errors.New("missing black king")
+	}
 
-		if p > 0 && i < boardX && j < boardY {
-			g.addPiece(i, j, p)
-			g.board[i][j] = p
-			j++ // 合法数据的情况
+	// 不走棋的一方不能被将军,否则走棋方可以直接吃将,将帅照面也属于这种情况
+	if (&chessGame{board: board}).isJiang(redPlayer) {
+		if redPlayer {
+			return board, false, errors.New("black is in check but red is to move")
 		}
+		return board, false, errors.New("red is in check but black is to move")
+	}
+	return board, redPlayer, nil
+}
+
+const pieceKinds = imgBlackJiang - imgRedShuai // 红黑双方各有7种棋子
+
+var (
+	// 每种棋子的最大数量,顺序为: 帅,仕,相,马,车,炮,兵
+	pieceLimit = [pieceKinds]int{1, 2, 2, 2, 2, 2, 5}
+	pieceNames = [pieceKinds]string{"king", "advisor", "bishop", "knight", "rook", "cannon", "pawn"}
+)
+
+// 棋子名称,用于错误信息
+func pieceName(p uint8) string {
+	if isRed(p) {
+		return "red " + pieceNames[p-imgRedShuai]
+	}
+	return "black " + pieceNames[p-imgBlackJiang]
+}
+
+// 判断棋子p能否出现在[x,y]位置,黑棋翻转到红棋一方判断
+func pieceSquare(p uint8, x, y int) bool {
+	if !isRed(p) {
+		p, x = p-pieceKinds, boardX-1-x
 	}
+	switch p {
+	case imgRedShuai:
+		return x >= 7 && y >= 3 && y <= 5 // 九宫格内
+	case imgRedShi:
+		return (x == 7 || x == 9) && (y == 3 || y == 5) || x == 8 && y == 4 // 九宫格的角和中心
+	case imgRedXiang:
+		return (x == 5 || x == 9) && (y == 2 || y == 6) || x == 7 && (y == 0 || y == 4 || y == 8) // 己方的七个象位
+	case imgRedBing:
+		return x <= 4 || x <= 6 && y%2 == 0 // 过河后任意位置,过河前只能在兵的初始列
+	}
+	return true
+}
+
+// 设置开局局面,fen不合法时返回错误且不修改当前开局局面,调用 reset 后生效
+func (g *chessGame) setStartFEN(fen string) error {
+	board, redPlayer, err := parseFEN(fen)
+	if err != nil {
+		return err
+	}
+	g.startFEN, g.startBoard, g.startRed = fen, board, redPlayer
+	return nil
 }
 
 func (g *chessGame) storeFEN() string {
@@ -243,3 +275,34 @@ func (g *chessGame) storeFEN() string {
 	}
 	return string(s)
 }
+
+// 走法转换为ICCS坐标格式,例如: h2e2
+// 介绍: https://www.xqbase.com/protocol/cchess_move.htm
+// 纵线从红方左侧起依次为a~i,横线从红方底线起依次为0~9
+func (m moveXY) iccs() string {
+	return string([]byte{
+		byte('a' + m.y0), byte('0' + boardX - 1 - m.x0),
+		byte('a' + m.y1), byte('0' + boardX - 1 - m.x1),
+	})
+}
+
+// 解析ICCS坐标格式走法,兼容 h2-e2 和大写字母的写法
+func parseICCS(s string) (m moveXY, ok bool) {
+	if len(s) == 5 && s[2] == '-' {
+		s = s[:2] + s[3:]
+	}
+	if len(s) != 4 {
+		return
+	}
+
+	var v [4]int
+	for i := 0; i < 4; i += 2 {
+		c := s[i] | 0x20 // 转小写
+		if c < 'a' || c > 'i' || s[i+1] < '0' || s[i+1] > '9' {
+			return
+		}
+		v[i], v[i+1] = int(c-'a'), boardX-1-int(s[i+1]-'0')
+	}
+	m.y0, m.x0, m.y1, m.x1 = v[0], v[1], v[2], v[3]
+	return m, true
+}
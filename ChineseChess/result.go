@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+)
+
+const (
+	resignMoves = 3  // ai 连续这么多步评估都低于认输分数才认输,避免偶尔的误判
+	drawMargin  = 50 // 子力较少时 ai 稍占优势也接受和棋,这点优势很难转化为胜利
+)
+
+// 对局结束,结束原因保存在 g.showMsg,同时记录到日志
+func (g *chessGame) endGame() {
+	g.gameOver = true
+	g.drawOffer, g.notice = 0, 0
+	log.Printf("game over after %d moves: %s", len(g.record), langText["en"][g.showMsg])
+}
+
+// 本方是否红方,联网时主机执红,人机对战时人执红,人人对战时为当前走棋方
+func (g *chessGame) myRed() bool {
+	if g.net != nil {
+		return g.net.host
+	}
+	if g.aiStatus.Load() > aiOff {
+		return true
+	}
+	return g.redPlayer
+}
+
+// ai 根据上一步搜索的评估决定是否接受和棋,不占优时接受,双方子力都较少时稍占优势也接受
+// ai 还没有走过棋时 aiValue 是开局时的局面评估
+func (g *chessGame) acceptDraw() bool {
+	if g.attackers(false) <= 2 && g.attackers(true) <= 2 {
+		return g.aiValue <= drawMargin
+	}
+	return g.aiValue <= 0
+}
+
+// 进攻子力,车算2个,马炮算1个
+func (g *chessGame) attackers(red bool) (n int) {
+	ju, ma, pao := imgBlackJu, imgBlackMa, imgBlackPao
+	if red {
+		ju, ma, pao = imgRedJu, imgRedMa, imgRedPao
+	}
+	for i := 0; i < boardX; i++ {
+		for j := 0; j < boardY; j++ {
+			switch g.board[i][j] {
+			case ju:
+				n += 2
+			case ma, pao:
+				n++
+			}
+		}
+	}
+	return
+}
+
+// ai 搜索完成后判断是否认输,value 为 ai 一方的评估分数,在 ai 协程中调用
+func (g *chessGame) checkResign(value int) {
+	g.aiValue = value
+	if g.resign > 0 && value < -g.resign {
+		g.aiLosing++
+	} else {
+		g.aiLosing = 0
+	}
+	g.aiResign = g.aiLosing >= resignMoves
+}
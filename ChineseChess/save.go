@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const saveInterval = 60 // 每秒检查一次对局是否有变化,有变化才写文件
+
+// 自动保存的对局,关闭窗口时和对局中定时保存,下次启动时可以选择继续
+type savedGame struct {
+	FEN   string   `json:"fen"`             // 开局局面
+	Moves []string `json:"moves"`           // 全部走法,ICCS格式
+	AI    bool     `json:"ai"`              // 是否人机对战
+	Seed  int64    `json:"seed"`            // 随机数种子,恢复后 ai 的选择和原来的对局一样
+	Nodes bool     `json:"nodes,omitempty"` // 指定了种子,ai 按节点数而不是时间限制思考
+}
+
+// 保存对局的文件,在用户配置目录下,取不到目录时(例如在浏览器中)不保存
+func savePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "LittleGame", "ChineseChess.json")
+}
+
+// 读取上次没有下完的对局,没有时返回nil
+func loadSaved() *savedGame {
+	name := savePath()
+	if name == "" {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("read saved game: %v", err)
+		}
+		return nil
+	}
+
+	s := new(savedGame)
+	if err = json.Unmarshal(data, s); err != nil {
+		log.Printf("read saved game %s: %v", name, err)
+		return nil
+	}
+	return s
+}
+
+// 当前对局的保存内容,联网对战,对局已经结束或者还没有走棋时返回空
+func (g *chessGame) saveData() string {
+	if g.net != nil || g.gameOver || len(g.record) == 0 {
+		return ""
+	}
+	s := savedGame{FEN: g.startFEN, AI: g.aiStatus.Load() > aiOff, Seed: g.seed, Nodes: g.nodeLimit > 0}
+	for _, m := range g.record {
+		s.Moves = append(s.Moves, m.iccs())
+	}
+	data, _ := json.Marshal(&s)
+	return string(data)
+}
+
+// 对局有变化时写入文件,没有需要保存的对局时删除文件
+func (g *chessGame) autoSave() {
+	data := g.saveData()
+	if data == g.saved {
+		return
+	}
+	g.saved = data
+	writeSaved(data)
+}
+
+// 写入保存的对局,data 为空时删除文件
+func writeSaved(data string) {
+	name := savePath()
+	if name == "" {
+		return
+	}
+	if data == "" {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("remove saved game: %v", err)
+		}
+		return
+	}
+
+	// 先写临时文件再改名,写到一半退出也不会破坏上次保存的对局
+	err := os.MkdirAll(filepath.Dir(name), 0o755)
+	if err == nil {
+		if err = os.WriteFile(name+".tmp", []byte(data), 0o644); err == nil {
+			err = os.Rename(name+".tmp", name)
+		}
+	}
+	if err != nil {
+		log.Printf("save game: %v", err) // 保存失败只记录日志,不影响继续下棋
+	}
+}
+
+// 恢复保存的对局,从开局局面重走全部走法,人机对战轮到 ai 时 ai 接着思考
+// 同时换成保存的随机数种子,重新生成 zobrist 表,和命令行指定的种子无关
+func (g *chessGame) restore(s *savedGame) error {
+	if s.Seed <= 0 {
+		return fmt.Errorf("invalid seed %d", s.Seed)
+	}
+	if err := g.setStartFEN(s.FEN); err != nil {
+		return fmt.Errorf("invalid fen %q: %w", s.FEN, err)
+	}
+	g.seed, g.nodeLimit = s.Seed, 0
+	if s.Nodes {
+		g.nodeLimit = aiNodeLimit
+	}
+	initZobrist(g.seed)
+
+	if s.AI {
+		g.aiStatus.Store(aiOn)
+	} else {
+		g.aiStatus.Store(aiOff)
+	}
+	g.reset()
+
+	for i, mv := range s.Moves {
+		m, ok := parseICCS(mv)
+		if ok && !g.gameOver {
+			qz := g.board[m.x0][m.y0]
+			ok = qz > 0 && isRed(qz) == g.redPlayer && g.legalMove(m)
+		}
+		if !ok {
+			return fmt.Errorf("illegal move %d %q", i+1, mv)
+		}
+
+		g.chessMove.x0, g.chessMove.y0 = m.x0, m.y0
+		if err := g.stepNext(m.x1, m.y1, -1); err != nil {
+			return err
+		}
+	}
+	g.stopAnim()
+	g.saved = g.saveData()
+	log.Printf("resumed a saved game after %d moves, seed: %d", len(g.record), g.seed)
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRestoreSeed(t *testing.T) {
+	// 恢复时使用保存的种子,不是启动时的种子,再保存的内容和原来一样
+	s := &savedGame{FEN: boardStart, Moves: []string{"h2e2", "h9g7"}, AI: true, Seed: 7, Nodes: true}
+	g := newNetTestGame(t, true)
+	g.net = nil
+	if err := g.restore(s); err != nil {
+		t.Fatal(err)
+	}
+	defer initZobrist(1)
+	if g.seed != 7 || g.nodeLimit != aiNodeLimit || len(g.record) != 2 {
+		t.Fatalf("seed %d, node limit %d, %d moves", g.seed, g.nodeLimit, len(g.record))
+	}
+	data, _ := json.Marshal(s)
+	if g.saveData() != string(data) {
+		t.Fatalf("saved %s, want %s", g.saveData(), data)
+	}
+
+	s.Seed = 0
+	if err := newNetTestGame(t, true).restore(s); err == nil {
+		t.Fatal("restored a save without seed")
+	}
+}
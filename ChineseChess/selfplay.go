@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jan-bar/LittleGame/internal/match"
+)
+
+/*
+相邻棋力等级之间自我对弈,统计高一级的得分率,用来检查 skillLevels 的等级分,不打开游戏窗口,用法:
+
+	ChineseChess selfplay -games 20 -seed 1
+
+每局使用不同的种子,按节点数限制思考,相同参数的结果完全一样
+双方交替执红,不使用开局库,超过 -plies 步数还没有结束的对局算和棋
+*/
+func selfPlay(args []string) error {
+	set := flag.NewFlagSet("selfplay", flag.ExitOnError)
+	fg := set.Int("games", 20, "games between each pair of adjacent skill levels")
+	fs := set.Int64("seed", 1, "random seed of the first game, the next games use the following seeds")
+	fp := set.Int("plies", 200, "a game still going after this many plies counts as a draw")
+	fm := set.Int("max", 0, "only play the pairs of levels up to this rating, 0 means all pairs and full strength")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if *fg <= 0 || *fs <= 0 || *fp <= 0 {
+		return fmt.Errorf("games, seed and plies must be positive")
+	}
+
+	initZobrist(*fs)
+	levels := make([]*skillLevel, 0, len(skillLevels)+1)
+	for i := range skillLevels {
+		levels = append(levels, &skillLevels[i])
+	}
+	if *fm <= 0 {
+		levels = append(levels, nil) // 最后和完整棋力比较
+	}
+
+	for i := 1; i < len(levels) && (*fm <= 0 || levels[i].elo <= *fm); i++ {
+		var score match.Score // 高一级的成绩
+		for n := 0; n < *fg; n++ {
+			// 偶数局高一级的执红,奇数局执黑
+			red, black := levels[i], levels[i-1]
+			if n%2 == 1 {
+				red, black = black, red
+			}
+			r, _, err := selfPlayGame(*fs+int64(n), *fp, red, black)
+			if err != nil {
+				return err
+			}
+			if n%2 == 1 {
+				r = -r
+			}
+			score.Add(r)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%s vs %s: %v\n", skillName(levels[i]), skillName(levels[i-1]), score)
+	}
+	return nil
+}
+
+// 下一局棋,返回红方的结果和全部走法,赢了为1,和棋为0,输了为-1,等级为nil时使用完整棋力
+func selfPlayGame(seed int64, plies int, red, black *skillLevel) (int, []moveXY, error) {
+	g := &chessGame{
+		hashTable:    make(map[uint32]*hashTable, hashMask+1),
+		historyTable: make(map[int]int, 8000),
+		killerTable:  make(map[int]*[2]moveXY, limitMaxDepth),
+		seed:         seed,
+		nodeLimit:    aiNodeLimit,
+	}
+	if err := g.setStartFEN(boardStart); err != nil {
+		return 0, nil, err
+	}
+	g.reset()
+
+	for len(g.record) < plies && !g.gameOver {
+		g.skill, g.aiPlayer = red, !g.redPlayer // ai 为轮到走棋的一方搜索
+		if !g.redPlayer {
+			g.skill = black
+		}
+		g.search()
+		m := g.chessMove
+		g.chessMove.x0, g.chessMove.y0 = m.x0, m.y0
+		if err := g.stepNext(m.x1, m.y1, -1); err != nil {
+			return 0, nil, err
+		}
+		if len(g.record) == 0 || g.record[len(g.record)-1] != m {
+			return 0, nil, fmt.Errorf("seed %d: ai played an illegal move %s", seed, m.iccs())
+		}
+	}
+	result := 0
+	if g.gameOver {
+		switch g.showMsg {
+		case msgRedWin, msgBlackLongCheck, msgBlackStalemate:
+			result = 1
+		case msgBlackWin, msgRedLongCheck, msgRedStalemate:
+			result = -1
+		}
+	}
+	return result, g.record, nil
+}
+
+func skillName(s *skillLevel) string {
+	if s == nil {
+		return "full"
+	}
+	return s.String()
+}
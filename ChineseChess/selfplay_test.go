@@ -0,0 +1,22 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelfPlayReproducible(t *testing.T) {
+	// 相同种子的自我对弈走法一样,selfplay 统计的得分率可以复现
+	low, high := &skillLevels[0], &skillLevels[1]
+	r1, m1, err := selfPlayGame(3, 40, low, high)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, m2, err := selfPlayGame(3, 40, low, high)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r1 != r2 || len(m1) == 0 || !slices.Equal(m1, m2) {
+		t.Fatalf("games differ: result %d %v, result %d %v", r1, m1, r2, m2)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 棋力等级,限制搜索深度,并按一定概率从根节点走法中选择分数差距不大的次优走法
+// 让初学者和小朋友偶尔也能赢,但不会走出白送棋子这种离谱的棋
+type skillLevel struct {
+	elo    int // 等级分,由相邻等级自我对弈的得分率推算,只能说明等级之间的差距
+	depth  int // 最大搜索深度
+	margin int // 可选走法与最佳走法的最大分差
+	chance int // 选择次优走法的概率,百分比
+}
+
+// 按等级分从低到高排列,不指定等级分时使用完整棋力
+// 搜索深度对棋力影响最大,同一深度再用分差和概率细分
+// 等级分从600开始,逐级加上 ChineseChess selfplay -games 20 -seed 1 测得的分差,取整到10:
+//
+//	840 对 600    +16 =0 -4  得分率80%  分差+241
+//	930 对 840    +11 =3 -6  得分率62%  分差+89
+//	1270 对 930   +16 =3 -1  得分率88%  分差+338
+//	1420 对 1270  +13 =2 -5  得分率70%  分差+147
+//	1580 对 1420  +14 =1 -5  得分率72%  分差+168
+//	1850 对 1580  +15 =3 -2  得分率82%  分差+269
+//	1940 对 1850  +10 =5 -5  得分率62%  分差+89
+//	1990 对 1940  +10 =3 -7  得分率57%  分差+53
+//	2330 对 1990  +17 =1 -2  得分率88%  分差+338
+//	完整棋力 对 2330  +20 =0 -0  全胜,分差无法估计
+//
+// 每对只下20局,得分率的误差在11%左右,得分率接近100%时分差误差更大
+var skillLevels = []skillLevel{
+	{elo: 600, depth: 1, margin: 60, chance: 50},
+	{elo: 840, depth: 1},
+	{elo: 930, depth: 2, margin: 40, chance: 40},
+	{elo: 1270, depth: 2, margin: 30, chance: 25},
+	{elo: 1420, depth: 2},
+	{elo: 1580, depth: 3, margin: 30, chance: 25},
+	{elo: 1850, depth: 3, margin: 15, chance: 10},
+	{elo: 1940, depth: 3},
+	{elo: 1990, depth: 4, margin: 30, chance: 25},
+	{elo: 2330, depth: 4},
+}
+
+// 根据等级分选择不高于它的最高等级,低于最低等级时使用最低等级,elo<=0返回nil表示完整棋力
+func findSkill(elo int) *skillLevel {
+	if elo <= 0 {
+		return nil
+	}
+	s := &skillLevels[0]
+	for i := range skillLevels {
+		if skillLevels[i].elo <= elo {
+			s = &skillLevels[i]
+		}
+	}
+	return s
+}
+
+func (s *skillLevel) String() string {
+	return fmt.Sprintf("elo:%d", s.elo)
+}
+
+// 按棋力等级重新选择 ai 走法,depth 为已完成的搜索深度
+// 对根节点每个走法用浅一层的完整窗口搜索打分,再随机选择分差不超过 margin 的走法
+func (g *chessGame) skillMove(depth int) {
+	var mvs []moveXY
+	if g.canStep(g.aiPlayer, &mvs, nil) {
+		return // 没棋了,保持原结果
+	}
+
+	vls := make([]int, len(mvs))
+	for i, v := range mvs {
+		sp, dp := g.board[v.x0][v.y0], g.board[v.x1][v.y1]
+		g.board[v.x1][v.y1] = sp
+		g.board[v.x0][v.y0] = 0
+		g.makeMove(v, sp, dp)
+
+		vls[i] = -g.searchFull(-mateValue, mateValue, depth-1, false)
+
+		g.board[v.x0][v.y0], g.board[v.x1][v.y1] = sp, dp
+		g.undoMakeMove(v, sp, dp)
+	}
+	sort.Stable(&sortMoveXY{mvs: mvs, vls: vls})
+
+	n := 1
+	if vls[0] < winValue && g.rand.Intn(100) < g.skill.chance {
+		// 没有找到杀棋时才考虑次优走法,且不选择会被对方杀棋的走法
+		for n < len(mvs) && vls[n] >= vls[0]-g.skill.margin && vls[n] > -winValue {
+			n++
+		}
+	}
+	g.chessMove = mvs[g.rand.Intn(n)]
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/*
+皮肤包,用 -skin 参数指定一个目录或者zip文件,里面的文件和 resources.zip 中同名,
+例如 ChessBoard.png,RedJu.png,Eat.wav,皮肤包中没有的文件使用内置资源
+
+图片大小必须和界面布局一致:
+	ChessBoard.png  520x576
+	棋子和 Select.png  不超过56x56
+*/
+
+// 打开皮肤包,返回的 io.Closer 在读取完资源后关闭
+func openSkin(name string) (fs.FS, io.Closer, error) {
+	st, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("skin: %w", err)
+	}
+	if st.IsDir() {
+		return os.DirFS(name), io.NopCloser(nil), nil
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("skin %s: not a directory or zip file: %w", name, err)
+	}
+	return zr, zr, nil
+}
+
+// 打开资源文件,皮肤包中有同名文件时优先使用,skin 为nil时只用内置资源
+func openResource(skin fs.FS, f *zip.File) (rc io.ReadCloser, fromSkin bool, err error) {
+	if skin != nil {
+		sf, err := skin.Open(f.Name)
+		if err == nil {
+			return sf, true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+	rc, err = f.Open()
+	return
+}
+
+// 检查皮肤包中图片的大小,不合适的图片会画到格子外面或者和棋盘对不齐
+func checkSkinImage(name string, b image.Rectangle) error {
+	var (
+		w, h  = b.Dx(), b.Dy()
+		exact = filepath.Base(name) == "ChessBoard.png"
+		want  = image.Pt(squareSize, squareSize)
+	)
+	if exact {
+		want = image.Pt(boardWidth, boardEdge+squareSize*10+boardEdge)
+	}
+	if exact && (w != want.X || h != want.Y) {
+		return fmt.Errorf("image is %dx%d, want %dx%d", w, h, want.X, want.Y)
+	}
+	if w > want.X || h > want.Y {
+		return fmt.Errorf("image is %dx%d, want at most %dx%d", w, h, want.X, want.Y)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 搜索统计信息,每次 ai 思考时清零,迭代加深每完成一层输出一次
+// 用于分析 ai 为什么有时秒走,有时长时间思考
+type searchStats struct {
+	depth int // 已完成的搜索深度
+	value int // 该深度的搜索分数
+
+	nodes  int // 完全搜索节点数
+	qNodes int // 静态搜索节点数
+
+	hashProbes int // 查询置换表次数
+	hashHits   int // 置换表命中次数(校验码相同)
+	hashCuts   int // 置换表直接返回分数的次数
+
+	nullTries int // 尝试空步裁剪次数
+	nullCuts  int // 空步裁剪成功次数
+
+	betaCuts    int // beta 截断总次数
+	hashMvCuts  int // 置换表走法产生的截断
+	killerCuts  int // 杀手走法产生的截断
+	historyCuts int // 按历史表排序的其他走法产生的截断
+
+	start time.Time     // 开始思考时间
+	iter  time.Time     // 本层开始时间
+	used  time.Duration // 本层耗时
+}
+
+func (s *searchStats) reset() {
+	*s = searchStats{start: time.Now()}
+	s.iter = s.start
+}
+
+// 完成一层搜索,记录深度,分数和耗时
+func (s *searchStats) finish(depth, value int) {
+	now := time.Now()
+	s.depth, s.value = depth, value
+	s.used, s.iter = now.Sub(s.iter), now
+}
+
+// 总搜索节点数
+func (s *searchStats) total() int {
+	return s.nodes + s.qNodes
+}
+
+// 百分比,分母为0时返回0
+func percent(a, b int) int {
+	if b == 0 {
+		return 0
+	}
+	return a * 100 / b
+}
+
+func (s *searchStats) String() string {
+	return fmt.Sprintf("depth:%d value:%d nodes:%d qnodes:%d hash:%d/%d/%d null:%d/%d "+
+		"cuts:%d hash:%d%% killer:%d%% history:%d%% time:%v/%v",
+		s.depth, s.value, s.nodes, s.qNodes, s.hashCuts, s.hashHits, s.hashProbes,
+		s.nullCuts, s.nullTries, s.betaCuts, percent(s.hashMvCuts, s.betaCuts),
+		percent(s.killerCuts, s.betaCuts), percent(s.historyCuts, s.betaCuts),
+		s.used.Round(time.Millisecond), s.iter.Sub(s.start).Round(time.Millisecond))
+}
+
+// 叠加显示在棋盘上的统计信息,按行拆分避免超出棋盘宽度
+func (s *searchStats) lines() []string {
+	return []string{
+		fmt.Sprintf("depth:%d value:%d time:%v/%v", s.depth, s.value,
+			s.used.Round(time.Millisecond), s.iter.Sub(s.start).Round(time.Millisecond)),
+		fmt.Sprintf("nodes:%d qnodes:%d", s.nodes, s.qNodes),
+		fmt.Sprintf("hash cut/hit/probe:%d/%d/%d null cut/try:%d/%d",
+			s.hashCuts, s.hashHits, s.hashProbes, s.nullCuts, s.nullTries),
+		fmt.Sprintf("beta cuts:%d hash:%d%% killer:%d%% history:%d%%", s.betaCuts,
+			percent(s.hashMvCuts, s.betaCuts), percent(s.killerCuts, s.betaCuts),
+			percent(s.historyCuts, s.betaCuts)),
+	}
+}
@@ -0,0 +1,622 @@
+//go:build !nogui
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/jan-bar/LittleGame/internal/ui"
+)
+
+// 窗口界面: 图片,声音,输入和绘制,用 -tags nogui 编译时不包含这些,不依赖 ebiten
+
+func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	fh := flag.String("host", "", "host a LAN game and play red, e.g. :9527")
+	fj := flag.String("join", "", "join a LAN game and play black, e.g. 192.168.1.2:9527")
+	fl := flag.String("lang", "", "ui language, en or zh, default from system locale")
+	fs := flag.Int64("seed", 0, "random seed, 0 means pick one from the current time,\n"+
+		"a given seed also limits the AI by searched nodes instead of time, so games can be reproduced")
+	fe := flag.Int("elo", 0, "limit the AI to a rating measured by selfplay, 600 to 2330, 0 means full strength")
+	ff := flag.String("fen", boardStart, "start position in FEN, e.g. the position of an endgame puzzle")
+	ft := flag.Bool("stats", false, "print AI search statistics per depth and show them on the board")
+	fm := flag.Bool("moves", false, "show the move list panel, also toggled with the M key")
+	fr := flag.Int("resign", 500, "the AI resigns when its evaluation stays below -N for 3 moves, 0 means never")
+	fb := flag.String("book", "", "opening book file made by the book subcommand, used alongside the built-in book,\n"+
+		"its moves replace the built-in moves of the same position")
+	fo := flag.Bool("book-only", false, "use only the -book file, not the built-in opening book")
+	fk := flag.String("skin", "", "skin directory or zip file with images and sounds named as in resources.zip,\n"+
+		"missing files fall back to the built-in ones")
+	flag.Parse()
+	setLang(*fl)
+
+	game := &chessGame{
+		hashTable:    make(map[uint32]*hashTable, hashMask+1),
+		historyTable: make(map[int]int, 8000),
+		killerTable:  make(map[int]*[2]moveXY, limitMaxDepth),
+		seed:         *fs,
+		showStats:    *ft,
+		showMoves:    *fm,
+		resign:       *fr,
+		book:         make(openingBook),
+		bookFile:     *fb,
+		bookOnly:     *fo,
+		skin:         *fk,
+		skill:        findSkill(*fe),
+	}
+	if game.seed == 0 {
+		game.seed = time.Now().UnixNano()%999999 + 1 // 较短的种子方便反馈问题
+	} else {
+		game.nodeLimit = aiNodeLimit
+	}
+	log.Printf("seed: %d", game.seed)
+	if err := game.setStartFEN(*ff); err != nil {
+		log.Fatalf("invalid fen %q: %v", *ff, err)
+	}
+	initZobrist(game.seed)
+	err := game.loadResources()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *fh != "" {
+		game.net, err = newNetPlayer(true, *fh)
+	} else if *fj != "" {
+		game.net, err = newNetPlayer(false, *fj)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	game.reset() // 开局
+	if game.net == nil {
+		game.resume = loadSaved() // 上次没有下完的对局,询问是否继续
+	}
+
+	game.setWindowSize()
+	ebiten.SetWindowClosingHandled(true) // 关闭窗口前保存对局
+	ebiten.SetWindowTitle(lang[msgTitle])
+	if err = ebiten.RunGame(game); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// 界面使用的数据,嵌入在 chessGame 中
+type gameUI struct {
+	images [imgLength]*ebiten.Image   // 所需图片资源
+	audios [musicLength]*audio.Player // 所需音频资源
+
+	// 输入fen的文本框,为nil时表示没有打开
+	fenBox *fenBox
+	// 走棋动画和正在拖动的棋子,为nil时表示没有
+	anim *moveAnim
+	drag *dragPiece
+}
+
+func (g *chessGame) Layout(_, _ int) (int, int) {
+	if g.showMoves {
+		return boardWidth + panelWidth, boardHeight
+	}
+	return boardWidth, boardHeight
+}
+
+func (g *chessGame) Update() (err error) {
+	if err = g.updateSave(); err != nil {
+		return // 关闭窗口,已经保存对局
+	}
+	if g.updateResume() {
+		return // 等待选择是否继续上次的对局
+	}
+	g.updateAnim()
+	switch g.aiStatus.Load() {
+	case aiThink:
+		return // ai 正在思考,忽略其他任何操作
+	case aiPlay:
+		if g.aiResign && !g.gameOver { // ai 认输
+			g.showMsg = msgBlackResign
+			g.endGame()
+			err = g.playAudio(musicGameWin)
+		} else if !g.gameOver { // 游戏没结束,黑棋落子
+			if err = g.clickSquare(g.chessMove.x1, g.chessMove.y1); err != nil {
+				return
+			}
+		}
+		g.aiStatus.Store(aiOn)
+		return // ai模拟黑棋落子,恢复状态
+	case aiOn:
+		if !g.redPlayer && !g.gameOver {
+			g.copy = g.board // ai 思考时,界面用 g.copy 渲染
+			g.aiStatus.Store(aiThink)
+			go g.ai() // 轮到黑棋,设置状态,ai思考中,并启动 ai 协程
+			return
+		}
+	}
+
+	if g.net != nil {
+		if err = g.netUpdate(); err != nil {
+			return
+		}
+	}
+
+	if g.fenBox != nil {
+		g.updateFENBox()
+		return // 文本框打开时,其他按键和鼠标操作都无效
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) && (g.net == nil || g.net.host) {
+		g.fenBox = &fenBox{text: g.startFEN, paste: make(chan pasteResult, 1)}
+		return
+	}
+	if g.updateMoveList() {
+		return // 棋谱面板或历史局面处理了本次输入
+	}
+	if ok, err := g.updateResult(); ok || err != nil {
+		return err // 认输或提和
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if g.net != nil {
+			if g.net.host {
+				g.reset() // 联网对战只有主机可以重新开始,并同步给对方
+				g.netSync()
+			}
+			return
+		}
+
+		if g.chessMove.x0 == -1 {
+			// 在初始化时,按空格键切换 ai对战 / 人人对战
+			if !g.aiStatus.CompareAndSwap(aiOff, aiOn) {
+				g.aiStatus.Store(aiOff)
+			}
+		} // else {} 玩到中途按空格只会重新开始,不切换模式
+		g.reset()
+		return
+	}
+
+	if g.gameOver {
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			if g.net == nil {
+				g.reset()
+			} else if g.net.host {
+				g.reset()
+				g.netSync()
+			}
+		}
+	} else if g.net == nil || g.net.myTurn(g.redPlayer) {
+		x, y := ebiten.CursorPosition()
+		// 鼠标坐标转换为g.board[x][y],点击或拖动合法则进行走棋逻辑
+		x, y = (y-topY)/squareSize, (x-topX)/squareSize
+		err = g.updateDrag(x, y)
+	}
+	return
+}
+
+func (g *chessGame) Draw(screen *ebiten.Image) {
+	aiStatus, board, mv := g.aiStatus.Load(), g.board, g.chessMove
+	if aiStatus == aiThink {
+		board = g.copy // ai 思考时,画界面用 g.copy, g.board 会用于计算
+	}
+	if g.view >= 0 {
+		board, mv = g.viewBoard, moveXY{x0: -1, x1: -1} // 查看历史局面
+		if g.view > 0 {
+			mv = g.record[g.view-1]
+		}
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	screen.DrawImage(g.images[imgChessBoard], op)
+
+	var (
+		i, j int
+
+		geoMReset = func(i, j, off int) {
+			op.GeoM.Reset()
+			// 图像向右是X,向下是Y,但是数组向下是i,向右是j
+			// [8,13]是棋盘左上角起始点,每个棋子长宽squareSize
+			xp, yp := float64(j*squareSize+topX), float64(i*squareSize+topY+off)
+			op.GeoM.Translate(xp, yp)
+		}
+	)
+	for i = 0; i < boardX; i++ {
+		for j = 0; j < boardY; j++ {
+			if qz := board[i][j]; qz > 0 {
+				if g.view < 0 && g.floating(i, j) {
+					continue // 正在拖动或动画中的棋子最后再画
+				}
+				geoMReset(i, j, 0)
+				screen.DrawImage(g.images[qz], op)
+
+				if mv.x1 == i && mv.y1 == j {
+					// 棋子被选中,在相对偏移-5位置画圆圈
+					op.GeoM.Translate(0, -5)
+					screen.DrawImage(g.images[imgSelect], op)
+				}
+			} else if mv.x0 == i && mv.y0 == j {
+				// 该棋子上次所在位置,圈起来,提示该棋子从哪里走
+				geoMReset(i, j, -5)
+				screen.DrawImage(g.images[imgSelect], op)
+			}
+		}
+	}
+
+	if g.view < 0 {
+		g.drawFloating(screen, &board)
+	}
+	if g.showMoves {
+		g.drawMoveList(screen)
+	}
+
+	var show string
+	if g.resume != nil {
+		show = fmt.Sprintf(lang[msgResume], len(g.resume.Moves))
+	} else if g.view >= 0 {
+		show = fmt.Sprintf(lang[msgViewing], g.view, len(g.record))
+	} else if g.gameOver {
+		if g.net == nil || g.net.host {
+			show = lang[g.showMsg] + lang[msgClickRestart]
+		} else {
+			show = lang[g.showMsg] + lang[msgWaitRestart]
+		}
+	} else if g.drawOffer > 0 {
+		show = lang[g.drawOffer]
+	} else if g.notice > 0 {
+		show = lang[g.notice]
+	} else if g.net != nil {
+		show = g.net.status(g.redPlayer)
+	} else {
+		switch aiStatus {
+		case aiOff:
+			show = lang[msgAIOff]
+		case aiOn:
+			show = lang[msgAIOn]
+		case aiThink:
+			show = lang[msgAIThink]
+		}
+	}
+	if g.fenBox == nil {
+		ui.DrawText(screen, show, 5, boardHeight-20)
+	}
+
+	if st := g.lastStats.Load(); st != nil {
+		for i, s := range st.lines() {
+			ui.DrawText(screen, s, topX+5, topY+5+i*14) // 在棋盘左上角叠加显示搜索统计
+		}
+	}
+
+	show = fmt.Sprintf("seed:%d", g.seed)
+	if g.skill != nil {
+		show = g.skill.String() + " " + show
+	}
+	w := int(text.Advance(show, ui.Face))
+	ui.DrawText(screen, show, boardWidth-5-w, boardHeight-20)
+
+	if g.fenBox != nil {
+		g.drawFENBox(screen, boardWidth-20-w)
+	}
+}
+
+func (g *chessGame) playAudio(music int) (err error) {
+	if music >= musicSelect && music < musicLength && g.audios[music] != nil { // 测试中没有加载声音
+		p := g.audios[music]
+		if err = p.Rewind(); err != nil {
+			return
+		}
+		p.Play()
+	}
+	return
+}
+
+func (g *chessGame) loadResources() error {
+	var (
+		data     = bytes.NewReader(resources)
+		audioCtx = audio.NewContext(48000)
+	)
+
+	zr, err := zip.NewReader(data, data.Size())
+	if err != nil {
+		return err
+	}
+
+	var skin fs.FS
+	if g.skin != "" {
+		var sc io.Closer
+		if skin, sc, err = openSkin(g.skin); err != nil {
+			return err
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer sc.Close()
+	}
+
+	for _, f := range zr.File {
+		i, ok := resNames[f.Name]
+		if !ok {
+			continue
+		}
+
+		var fromSkin bool
+		err = func() error {
+			var (
+				fr  io.ReadCloser
+				err error
+			)
+			if filepath.Ext(f.Name) == ".dat" {
+				fr, err = f.Open() // 开局库不属于皮肤,用 -book 参数加载
+			} else {
+				fr, fromSkin, err = openResource(skin, f)
+			}
+			if err != nil {
+				return err
+			}
+			//goland:noinspection GoUnhandledErrorResult
+			defer fr.Close()
+
+			switch filepath.Ext(f.Name) {
+			case ".png":
+				img, err := png.Decode(fr)
+				if err != nil {
+					return err
+				}
+				if fromSkin {
+					if err = checkSkinImage(f.Name, img.Bounds()); err != nil {
+						return err
+					}
+				}
+				g.images[i] = ebiten.NewImageFromImage(img)
+			case ".wav":
+				wr, err := wav.DecodeWithSampleRate(audioCtx.SampleRate(), fr)
+				if err != nil {
+					return err
+				}
+				wd, err := io.ReadAll(wr)
+				if err != nil {
+					return err
+				}
+				g.audios[i] = audioCtx.NewPlayerFromBytes(wd)
+			case ".dat":
+				if !g.bookOnly {
+					return g.book.load(fr)
+				}
+			}
+			return nil
+		}()
+		if err != nil {
+			if fromSkin {
+				return fmt.Errorf("skin %s: %s: %w", g.skin, f.Name, err)
+			}
+			return err
+		}
+	}
+
+	if g.bookFile != "" {
+		f, err := os.Open(g.bookFile)
+		if err != nil {
+			return err
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer f.Close()
+		return g.book.load(f)
+	}
+	return nil
+}
+
+// 处理棋谱面板的显示切换,滚动,点击和历史局面浏览,返回true表示输入已处理
+func (g *chessGame) updateMoveList() bool {
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.showMoves = !g.showMoves
+		g.setWindowSize()
+		return true
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		if g.view < 0 {
+			g.setView(len(g.record) - 1)
+		} else if g.view > 0 {
+			g.setView(g.view - 1)
+		}
+		return true
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		if g.view >= 0 {
+			g.setView(g.view + 1)
+		}
+		return true
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape) && g.view >= 0:
+		g.setView(-1)
+		return true
+	}
+
+	if g.showMoves {
+		_, dy := ebiten.Wheel()
+		last, _ := g.moveSlot(len(g.record) - 1)
+		if dy > 0 && g.panelScroll > 0 {
+			g.panelScroll--
+		} else if dy < 0 && g.panelScroll+panelRows <= last+1 { // 最后一行下面可能还有对局结果
+			g.panelScroll++
+		}
+	}
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	cx, cy := ebiten.CursorPosition()
+	if cx < boardWidth {
+		if g.view >= 0 {
+			g.setView(-1) // 查看历史局面时棋盘只读,点击棋盘回到对局
+			return true
+		}
+		return false
+	}
+	if cy >= panelTop {
+		row, col := g.panelScroll+(cy-panelTop)/panelRow, 0
+		if cx-boardWidth >= panelBlackX-4 {
+			col = 1
+		}
+		i := row*2 + col
+		if !g.startRed {
+			i--
+		}
+		if i >= 0 && i < len(g.record) {
+			g.setView(i + 1) // 点击最后一步时回到对局
+		}
+	}
+	return true
+}
+
+func (g *chessGame) setWindowSize() {
+	if g.showMoves {
+		ebiten.SetWindowSize(boardWidth+panelWidth, boardHeight)
+	} else {
+		ebiten.SetWindowSize(boardWidth, boardHeight)
+	}
+}
+
+func (g *chessGame) drawMoveList(screen *ebiten.Image) {
+	vector.DrawFilledRect(screen, boardWidth, 0, panelWidth, boardHeight, color.RGBA{R: 0x40, G: 0x30, B: 0x20, A: 0xff}, false)
+
+	cur := len(g.record) - 1 // 高亮当前局面的最后一步
+	if g.view >= 0 {
+		cur = g.view - 1
+	}
+	for i, s := range g.notes {
+		row, col := g.moveSlot(i)
+		if row < g.panelScroll || row >= g.panelScroll+panelRows {
+			continue
+		}
+
+		y := panelTop + (row-g.panelScroll)*panelRow
+		x := boardWidth + panelRedX
+		if col == 1 {
+			x = boardWidth + panelBlackX
+		}
+		if col == 0 || i == 0 {
+			ui.DrawText(screen, fmt.Sprintf("%3d.", row+1), boardWidth+6, y)
+		}
+		if i == cur {
+			vector.DrawFilledRect(screen, float32(x-4), float32(y-3), 72, panelRow, color.RGBA{R: 0xb0, G: 0x70, B: 0x10, A: 0xff}, false)
+		}
+		ui.DrawText(screen, s, x, y)
+	}
+
+	if g.gameOver {
+		// 对局结束原因记在棋谱最后
+		row := 0
+		if len(g.notes) > 0 {
+			row, _ = g.moveSlot(len(g.notes) - 1)
+			row++
+		}
+		if row >= g.panelScroll && row < g.panelScroll+panelRows {
+			ui.DrawText(screen, lang[g.showMsg], boardWidth+6, panelTop+(row-g.panelScroll)*panelRow)
+		}
+	}
+}
+
+// 处理认输和提和: R键认输,D键提和,收到提和时Y键同意,N键拒绝,返回true表示输入已处理
+func (g *chessGame) updateResult() (bool, error) {
+	if g.gameOver || g.view >= 0 || g.net != nil && !g.net.online {
+		return false, nil
+	}
+	red := g.myRed()
+	// 联网时任何时候都可以认输和提和,本地只有轮到自己时才可以
+	myTurn := g.net != nil || red == g.redPlayer
+
+	switch {
+	case g.drawOffer > 0 && inpututil.IsKeyJustPressed(ebiten.KeyY):
+		g.showMsg = msgDrawAgreed
+		g.endGame()
+		if g.net != nil {
+			g.netEnd()
+		}
+		return true, nil
+	case g.drawOffer > 0 && inpututil.IsKeyJustPressed(ebiten.KeyN):
+		g.drawOffer = 0
+		if g.net != nil {
+			g.net.send("DRAW decline")
+		}
+		return true, nil
+	case myTurn && inpututil.IsKeyJustPressed(ebiten.KeyR):
+		music := musicGameWin
+		if g.showMsg = msgBlackResign; red {
+			g.showMsg = msgRedResign
+		}
+		if g.net == nil && g.aiStatus.Load() > aiOff {
+			music = musicGameLose // 人机对战认输,播放失败音乐
+		}
+		g.endGame()
+		if g.net != nil {
+			g.netEnd()
+		}
+		return true, g.playAudio(music)
+	case myTurn && g.drawOffer == 0 && inpututil.IsKeyJustPressed(ebiten.KeyD):
+		switch {
+		case g.net != nil:
+			g.net.send("DRAW offer")
+			g.notice = msgDrawOffered
+		case g.aiStatus.Load() > aiOff:
+			if !g.acceptDraw() {
+				g.notice = msgDrawDeclined
+				break
+			}
+			g.showMsg = msgDrawAgreed
+			g.endGame()
+		default:
+			g.drawOffer = msgBlackDrawOffer // 人人对战,由对方按键答复
+			if red {
+				g.drawOffer = msgRedDrawOffer
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// 定时保存对局,关闭窗口时保存后返回 ebiten.Termination 结束游戏
+func (g *chessGame) updateSave() error {
+	if ebiten.IsWindowBeingClosed() {
+		if g.resume == nil {
+			g.autoSave() // 还没选择是否继续上次的对局时,保存的对局留到下次启动
+		}
+		return ebiten.Termination
+	}
+	if g.saveTick++; g.resume == nil && g.saveTick >= saveInterval {
+		g.saveTick = 0
+		g.autoSave()
+	}
+	return nil
+}
+
+// 启动时询问是否继续上次的对局,Y键继续,N键新开一局,返回true表示正在询问
+func (g *chessGame) updateResume() bool {
+	if g.resume == nil {
+		return false
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyY):
+		if err := g.restore(g.resume); err != nil {
+			log.Printf("resume saved game: %v", err)
+			g.reset() // 保存的对局有错误,从保存的开局局面重新开始
+		}
+		g.resume = nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyN):
+		g.resume = nil
+		writeSaved("") // 不再继续,删除保存的对局
+	}
+	return true
+}
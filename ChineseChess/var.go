@@ -26,13 +26,14 @@ const (
 )
 
 const (
-	musicSelect   = iota // 选子
-	musicPut             // 落子
-	musicEat             // 吃子
-	musicJiang           // 将军
-	musicGameWin         // 胜利
-	musicGameLose        // 失败
-	musicLength          // 音乐总长度
+	musicSelect    = iota // 选子
+	musicPut              // 落子
+	musicEat              // 吃子
+	musicJiang            // 将军
+	musicGameWin          // 胜利
+	musicGameLose         // 失败
+	musicStalemate        // 困毙
+	musicLength           // 音乐总长度
 )
 
 const (
@@ -192,13 +193,15 @@ var (
 	}
 )
 
-func init() {
+// 使用随机数种子生成zobrist表,相同的种子生成相同的表,确保ai搜索结果可以复现
+func initZobrist(seed int64) {
 	var (
+		r = rand.New(rand.NewSource(seed))
 		// 用于生成非零且不重复的随机数
 		tmp    = map[uint32]struct{}{0: {}}
 		myRand = func() uint32 {
 			for {
-				u := rand.Uint32()
+				u := r.Uint32()
 				_, ok := tmp[u]
 				if !ok {
 					tmp[u] = struct{}{}
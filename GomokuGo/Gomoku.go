@@ -1,232 +1,295 @@
 package main
 
 import (
-	"bytes"
-	_ "embed"
-	"image"
-	"image/color"
-	_ "image/jpeg"
-	_ "image/png"
+	"flag"
 	"log"
 	"math/rand"
-	"strconv"
-
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/vector"
+	"sync/atomic"
+	"time"
 )
 
-func main() {
+// 界面版本和 brain 版本共用的命令行参数
+type gameFlags struct {
+	brain    *bool
+	selfPlay *int
+	seed     *int64
+	elo      *int
+	rule     *string
+	moveTime *time.Duration
+}
+
+// 定义共用的参数,需要在 flag.Parse 之前调用
+func newGameFlags() *gameFlags {
+	return &gameFlags{
+		brain: flag.Bool("brain", false, "run as a Gomocup brain without a window, read the protocol from stdin and reply on stdout,\n"+
+			"on a machine without a display build the brain with go build -tags nogui, which always runs as a brain"),
+		selfPlay: flag.Int("selfplay", 0, "play N games between each pair of adjacent skill levels without a window,\n"+
+			"print the score rates and exit, the results only depend on -seed, -rule and -time"),
+		seed: flag.Int64("seed", 0, "random seed, 0 means pick one from the current time,\n"+
+			"a given seed also limits the AI by searched nodes instead of time, so games can be reproduced"),
+		elo: flag.Int("elo", 0, "limit the AI to a rating measured by -selfplay, 1100 to 1180, 0 means full strength"),
+		rule: flag.String("rule", ruleNames[ruleFreestyle], "rule set, freestyle, renju or standard,\n"+
+			"renju forbids double-three, double-four and overline for the first player, who must make exactly five,\n"+
+			"standard lets both players play overlines but only exactly five wins"),
+		moveTime: flag.Duration("time", 3*time.Second, "thinking time of the computer for each move, e.g. 500ms or 10s,\n"+
+			"the deepest search finished in time is used, with -seed it is converted to a number of searched nodes"),
+	}
+}
+
+// 按解析后的参数创建对局,参数不对时退出
+func (f *gameFlags) newGomoku() *Gomoku {
+	rule, err := findRule(*f.rule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *f.moveTime <= 0 {
+		log.Fatalf("invalid time %v, must be positive", *f.moveTime)
+	}
+
 	g := &Gomoku{
-		searchDeep:   7,
+		size:         minBoardSize,
+		searchDeep:   10,
 		deepDecrease: 0.8,
-		countLimit:   10,
 		threshold:    1.1,
-		cache:        make(map[int64]*gomokuCache),
+		tt:           newTransTable(),
 		aiStatus:     make(chan int),
+		seed:         *f.seed,
+		rule:         rule,
+		moveTime:     *f.moveTime,
 	}
-	for i, v := range [][]byte{humImgData, comImgData, humImgWinData, comImgWinData, background} {
-		img, _, err := image.Decode(bytes.NewReader(v))
-		if err != nil {
-			log.Fatal(err)
-		}
-		g.img[i] = ebiten.NewImageFromImage(img)
+	g.setSkill(*f.elo)
+	if g.seed == 0 {
+		g.seed = time.Now().UnixNano()%999999 + 1 // 较短的种子方便反馈问题
+	} else {
+		g.nodeBudget = true
 	}
+	log.Printf("seed: %d", g.seed)
+	g.setSeed(g.seed)
+	return g
+}
 
+// 使用种子生成zobrist随机值,相同种子ai的置换表命中情况相同,确保结果可以复现
+// 棋盘的 hash code 是按落子异或出来的,换种子后要重新开始对局
+func (g *Gomoku) setSeed(seed int64) {
+	g.seed, g.zobristCode, g.zobrist = seed, 0, [maxBoardSize][maxBoardSize][2]int64{}
+	zr := rand.New(rand.NewSource(seed))
 	for g.zobristCode == 0 {
-		g.zobristCode = rand.Int63n(1000000000) // 初始化随机hash值
+		g.zobristCode = zr.Int63n(1000000000) // 初始化随机hash值
 	}
-
-	lineColor := color.RGBA{R: 0, G: 0, B: 0, A: 0xff}
-	for i := 0; i < boardSize; i++ {
-		for j := 0; j < boardSize; j++ {
+	for i := 0; i < maxBoardSize; i++ {
+		for j := 0; j < maxBoardSize; j++ {
 			for g.zobrist[i][j][0] == 0 { // 玩家随机值
-				g.zobrist[i][j][0] = rand.Int63n(1000000000)
+				g.zobrist[i][j][0] = zr.Int63n(1000000000)
 			}
 			for g.zobrist[i][j][1] == 0 { // 电脑随机值
-				g.zobrist[i][j][1] = rand.Int63n(1000000000)
+				g.zobrist[i][j][1] = zr.Int63n(1000000000)
 			}
 		}
-
-		var (
-			lt  = strconv.Itoa(i + 1)
-			ln  = 25 + 40*i // 通过调试得到计算数值
-			lnf = float32(ln)
-		)
-		// 为背景图片添加横竖线条,以及每个线条对应数字
-		vector.StrokeLine(g.img[4], 0, lnf, 650, lnf, 1,lineColor,false)
-		ebitenutil.DebugPrintAt(g.img[4], lt, 600, ln)
-		vector.StrokeLine(g.img[4], lnf, 0, lnf, 650,1, lineColor,false)
-		ebitenutil.DebugPrintAt(g.img[4], lt, ln, 610)
-	}
-
-	bgImg := ebiten.NewImage(screenWidth, screenHeight)
-	bgImg.DrawImage(g.img[4], nil)
-	ebitenutil.DebugPrintAt(bgImg, "press B to computer first,W to player first", 10, screenWidth)
-	g.img[4] = bgImg // 背景宽度比图片大,在最底下显示一些信息
-
-	go g.ai() // 启动协程运行ai
-
-	ebiten.SetWindowSize(screenWidth, screenHeight)
-	ebiten.SetWindowTitle("Gomoku Golang")
-	if err := ebiten.RunGame(g); err != nil {
-		log.Fatal(err)
 	}
 }
 
 const (
-	// 窗口宽一些,用于显示额外文字
-	screenWidth = 630
-	// 带颜色的图片长和宽
-	screenHeight = screenWidth + 20
-	// 棋盘横竖格子数
-	boardSize = 15
+	// 棋盘横竖格子数的范围,数组按最大的分配
+	minBoardSize = 15
+	maxBoardSize = 20
 
 	allNoneFlag = 0
 
-	humImgFlag    = 1 // 玩家棋子
-	comImgFlag    = 2 // 电脑棋子
-	humWinImgFlag = 3 // 玩家赢了的棋子
-	comWinImgFlag = 4 // 电脑赢了的棋子
+	// 对局双方,和电脑对弈时分别由玩家和电脑落子,棋子颜色由先后手决定,先手方是黑棋
+	humImgFlag    = 1 // 玩家一方的棋子
+	comImgFlag    = 2 // 电脑一方的棋子
+	humWinImgFlag = 3 // 玩家一方赢了时连五的棋子
+	comWinImgFlag = 4 // 电脑一方赢了时连五的棋子
 
 	statusComputerRun = 1 // 电脑正在思考中
-	statusWhiteWin    = 2 // 白棋赢了
-	statusBlackWin    = 3 // 黑棋赢了
+	statusWhiteWin    = 2 // 白棋(后手方)赢了
+	statusBlackWin    = 3 // 黑棋(先手方)赢了
+	statusDraw        = 4 // 和棋,棋盘下满或者连珠规则黑方只剩禁手点
+)
+
+// 对局模式,决定双方由玩家还是电脑落子
+const (
+	modeComputer = iota // 玩家和电脑对弈,按B键玩家执黑先手,按W键玩家执白后手
+	modeHuman           // 两个玩家在同一台电脑上轮流落子
+	modeAuto            // 电脑自己和自己对弈
 )
 
 var (
-	//go:embed White.png
-	humImgData []byte
-	//go:embed WhiteWin.png
-	humImgWinData []byte
-	//go:embed Black.png
-	comImgData []byte
-	//go:embed BlackWin.png
-	comImgWinData []byte
-	//go:embed background.jpg
-	background []byte
+	modeNames = [...]string{
+		modeComputer: "computer",
+		modeHuman:    "human",
+		modeAuto:     "auto",
+	}
+	// 各模式的操作提示
+	modeHelp = [...]int{
+		modeComputer: msgHelp,
+		modeHuman:    msgHelpHuman,
+		modeAuto:     msgHelpAuto,
+	}
 )
 
+// 根据名称查找对局模式
+func findMode(name string) (int, error) {
+	return findName("mode", modeNames[:], name)
+}
+
+// role 一方是否由电脑落子
+func (g *Gomoku) aiPlays(role int) bool {
+	switch g.mode {
+	case modeHuman:
+		return false
+	case modeAuto:
+		return true
+	}
+	return role == comImgFlag
+}
+
 //goland:noinspection SpellCheckingInspection
 type (
-	gomokuCache struct {
-		deep            int
-		score, maxScore float64
-	}
 	Gomoku struct {
-		// 缓存图片对象
-		img [5]*ebiten.Image
+		// 界面使用的图片等,不带界面的 brain 版本中为空
+		view
+		// 棋盘横竖格子数,数组只用前 size 行和列
+		size int
 		// 五子棋棋盘数据,另一个是界面显示
-		board, show [boardSize][boardSize]int
+		board, show [maxBoardSize][maxBoardSize]int
 		// 保存当前状态
 		status int
 		// 暂存赢了的5个棋子位置
 		win [5][2]int
 		// zobrist 棋盘每个位置的随机值
-		zobrist [boardSize][boardSize][2]int64
+		zobrist [maxBoardSize][maxBoardSize][2]int64
 		// zobrist 棋盘hash code
 		zobristCode int64
-		// 缓存 zobristCode 棋盘状态时的 deep,score
-		cache map[int64]*gomokuCache
+		// 置换表,缓存 zobristCode 棋盘状态的搜索结果
+		tt *transTable
 		// ai 通过该通道更新坐标
 		aiStatus chan int
 
-		comScore [boardSize][boardSize]float64 // 电脑分数
-		humScore [boardSize][boardSize]float64 // 玩家分数
+		comScore [maxBoardSize][maxBoardSize]float64 // 电脑分数
+		humScore [maxBoardSize][maxBoardSize]float64 // 玩家分数
 
-		searchDeep   int     // 搜索深度
+		searchDeep   int     // 最大搜索深度,没有超时的话逐层加深到这里
 		deepDecrease float64 // 按搜索深度递减分数,为了让短路径的结果比深路径的分数高
 		countLimit   int     // gen函数返回的节点数量上限,超过之后将会按照分数进行截断
 		threshold    float64 // 阈值
+
+		// 随机数种子,每局开始时用它重置 rand,相同种子和落子可以复现对局
+		seed int64
+		rand *rand.Rand
+		// 棋力等级,为nil时使用完整棋力
+		skill *skillLevel
+		// 对局规则,以及先手方(执黑棋,连珠规则中禁手只限制这一方)和界面显示用的副本
+		rule, black, showBlack int
+		// 对局模式,决定双方分别由玩家还是电脑落子
+		mode int
+		// 界面上标出的禁手点,只在主线程使用
+		showForbid [maxBoardSize][maxBoardSize]bool
+		// 开局规则,以及开局阶段的状态和界面显示用的副本
+		opening        int
+		open, showOpen openState
+		// 算杀的截止时间或节点数上限,搜索节点数,以及是否已经超时
+		killDeadline time.Time
+		killLimit    int
+		killNodes    int
+		killStop     bool
+		// 电脑每一步的思考时间,以及 maxMin 搜索的截止时间或节点数上限(零值不限),节点数和是否已经中止
+		moveTime    time.Duration
+		deadline    time.Time
+		searchLimit int
+		searchNodes int
+		searchStop  bool
+		// 指定了随机数种子时按节点数而不是时间限制思考,不受机器快慢影响,相同种子可以复现对局
+		nodeBudget bool
+		// 重新开始或者关闭窗口时由主线程设置,让 ai 放弃正在进行的计算,不再落子
+		cancel atomic.Bool
+		// 对局的落子顺序和界面显示用的副本,以及悔棋撤销的落子和角色,最后撤销的在最后
+		moves, showMoves [][2]int
+		redo             [][3]int
+		// 在棋子上显示手数,只在主线程使用
+		numbers bool
+		// 启动时找到的上次没有下完的对局,为nil时表示不用询问是否继续
+		resume *savedGame
+		// 最后一次保存的内容,以及距离下次检查保存的帧数
+		saved    string
+		saveTick int
 	}
 )
 
 func (g *Gomoku) reset() {
-	// 重新开始游戏,这里重置游戏棋盘数据
-	for i := 0; i < boardSize; i++ {
-		for j := 0; j < boardSize; j++ {
+	// 重新开始游戏,这里重置游戏棋盘数据,整个数组都清空,改变棋盘大小后不会留下棋盘外的数据
+	for i := 0; i < maxBoardSize; i++ {
+		for j := 0; j < maxBoardSize; j++ {
 			g.board[i][j] = allNoneFlag
+			g.comScore[i][j], g.humScore[i][j] = 0, 0
 		}
 	}
 	g.status = allNoneFlag // 清除标记
+	g.black = humImgFlag   // 默认玩家一方先手,start 时按B,W键设置
+	g.open = openState{}
+	g.moves, g.redo = g.moves[:0], g.redo[:0]
+	g.tt.clear() // 上一局的分数和先手方有关,不能再用
+	g.rand = rand.New(rand.NewSource(g.seed))
 }
 
-func (g *Gomoku) isWin(i, j, img, imgWin int) bool {
-	var x, y, cnt int
-	g.win[0][0], g.win[0][1] = i, j
-
+// role 在 i,j 落子后是否连五,连五时把这5个棋子换成赢了的图片
+func (g *Gomoku) isWin(i, j, role int) bool {
 	const five = 5
-	defer func() {
-		if cnt == five {
-			for x = 0; x < five; x++ {
+	exact := g.exactFive(role) // 连珠规则的黑方和标准规则的双方长连不算赢
+	img, imgWin := role, humWinImgFlag
+	if role == comImgFlag {
+		imgWin = comWinImgFlag
+	}
+
+	// 依次检查横,竖,两条斜线,从落点向两边数连续的同色棋子
+	for _, d := range directions {
+		g.win[0][0], g.win[0][1] = i, j
+		cnt := 1
+		for _, s := range [...]int{1, -1} {
+			for x, y := i+s*d[0], j+s*d[1]; g.stone(x, y) == img; x, y = x+s*d[0], y+s*d[1] {
+				if cnt < five {
+					g.win[cnt][0], g.win[cnt][1] = x, y
+				}
+				cnt++
+			}
+		}
+
+		if cnt == five || cnt > five && !exact {
+			for x := 0; x < five; x++ {
 				// 赢了,将5个棋子换成赢了时的状态
 				g.board[g.win[x][0]][g.win[x][1]] = imgWin
 			}
+			return true
 		}
-	}()
-
-	// 横向向左
-	for cnt, x = 1, i-1; cnt != five && x >= 0 && g.board[x][j] == img; x-- {
-		g.win[cnt][0] = x
-		g.win[cnt][1] = j
-		cnt++
-	}
-	// 横向向右
-	for x = i + 1; cnt != five && x < boardSize && g.board[x][j] == img; x++ {
-		g.win[cnt][0] = x
-		g.win[cnt][1] = j
-		cnt++
-	}
-	if cnt == five {
-		return true // 横向满足5个
-	}
-
-	// 纵向向上
-	for cnt, y = 1, j-1; cnt != five && y >= 0 && g.board[i][y] == img; y-- {
-		g.win[cnt][0] = i
-		g.win[cnt][1] = y
-		cnt++
-	}
-	// 纵向向下
-	for y = j + 1; cnt != five && y < boardSize && g.board[i][y] == img; y++ {
-		g.win[cnt][0] = i
-		g.win[cnt][1] = y
-		cnt++
-	}
-	if cnt == five {
-		return true // 纵向满足5个
 	}
+	return false
+}
 
-	// 从落点往左上
-	for cnt, x, y = 1, i-1, j-1; cnt != five && x >= 0 && y >= 0 && g.board[x][y] == img; x, y = x-1, y-1 {
-		g.win[cnt][0] = x
-		g.win[cnt][1] = y
-		cnt++
-	}
-	// 从落点往右下
-	for x, y = i+1, j+1; cnt != five && x < boardSize && y < boardSize && g.board[x][y] == img; x, y = x+1, y+1 {
-		g.win[cnt][0] = x
-		g.win[cnt][1] = y
-		cnt++
+// role 在 x,y 落子后的状态,连五时按棋子颜色返回哪一方赢了,对方没有可以落子的位置时和棋
+func (g *Gomoku) result(x, y, role int) int {
+	if g.isWin(x, y, role) {
+		if role == g.black {
+			return statusBlackWin
+		}
+		return statusWhiteWin
 	}
-	if cnt == five {
-		return true // 左上右下满足5个
+	if _, _, ok := g.anyPoint(opponent(role)); !ok {
+		return statusDraw
 	}
+	return allNoneFlag
+}
 
-	// 从落点往左下
-	for cnt, x, y = 1, i-1, j+1; cnt != five && x >= 0 && y < boardSize && g.board[x][y] == img; x, y = x-1, y+1 {
-		g.win[cnt][0] = x
-		g.win[cnt][1] = y
-		cnt++
-	}
-	// 从落点往右上
-	for x, y = i+1, j-1; cnt != five && x < boardSize && y >= 0 && g.board[x][y] == img; x, y = x+1, y-1 {
-		g.win[cnt][0] = x
-		g.win[cnt][1] = y
-		cnt++
+// role 一方可以落子的任意一个位置,棋盘下满或者连珠规则黑方只剩禁手点时返回false
+// 算杀和搜索只考虑已有棋子附近的位置,都没有结果时用它保证能落子
+func (g *Gomoku) anyPoint(role int) (x, y int, ok bool) {
+	for x = 0; x < g.size; x++ {
+		for y = 0; y < g.size; y++ {
+			if g.board[x][y] == allNoneFlag && (role != g.black || !g.forbidden(x, y)) {
+				return x, y, true
+			}
+		}
 	}
-	return cnt == five // 左下右上满足5个
+	return 0, 0, false
 }
 
 func (g *Gomoku) put(x, y, role int) {
@@ -243,86 +306,6 @@ func (g *Gomoku) remove(x, y int) {
 	g.updateScore(x, y)
 }
 
-func (g *Gomoku) Update() error {
-	// 这里的chan确保 g.status 是线程安全
-	select {
-	case s, ok := <-g.aiStatus:
-		if ok {
-			g.status = s
-		}
-	default:
-		if g.status == statusComputerRun {
-			return nil // ai 思考时不允许其他操作
-		}
-	}
-
-	var sendAI bool
-	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
-		g.reset() // 按下B键重新开始游戏,电脑先手,正中央下黑棋
-		g.put(boardSize/2, boardSize/2, comImgFlag)
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyW) {
-		g.reset() // 按下W键重新开始游戏,玩家先手
-	} else if g.status == allNoneFlag && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		x, y := ebiten.CursorPosition()
-		x, y = (x-9)/40, (y-9)/40 // 计算鼠标点击位置,此位置没有落子时才响应
-		if x >= 0 && y >= 0 && x < boardSize && y < boardSize && g.board[x][y] == allNoneFlag {
-			g.put(x, y, humImgFlag)
-			if g.isWin(x, y, humImgFlag, humWinImgFlag) {
-				g.status = statusWhiteWin // 人赢了,设置状态
-			} else {
-				g.status = statusComputerRun // AI正在思考中
-
-				sendAI = true
-			}
-		}
-	}
-
-	for i := 0; i < boardSize; i++ {
-		for j := 0; j < boardSize; j++ {
-			g.show[i][j] = g.board[i][j]
-		}
-	}
-
-	// 在将 g.board 复制到 g.show 之后才该ai发消息,确保线程安全
-	// 更新UI只用到了 g.status 和 g.show ,确保这两个变量线程安全就OK
-	if sendAI {
-		select {
-		case g.aiStatus <- 0: // 发信号让ai
-		default:
-		}
-	}
-	return nil
-}
-
-func (g *Gomoku) Draw(screen *ebiten.Image) {
-	screen.DrawImage(g.img[4], nil)
-	for i := 0; i < boardSize; i++ {
-		for j := 0; j < boardSize; j++ {
-			if b := g.show[i][j] - 1; b >= allNoneFlag {
-				op := &ebiten.DrawImageOptions{}
-				op.GeoM.Translate(float64(9+40*i), float64(9+40*j))
-				screen.DrawImage(g.img[b], op)
-			}
-		}
-	}
-
-	switch g.status {
-	case statusComputerRun:
-		ebitenutil.DebugPrintAt(screen,
-			"AI is thinking, please wait!", 300, screenWidth)
-	case statusWhiteWin:
-		ebitenutil.DebugPrintAt(screen,
-			"White has won, please restart the game!", 300, screenWidth)
-	case statusBlackWin:
-		ebitenutil.DebugPrintAt(screen,
-			"Black has won, please restart the game!", 300, screenWidth)
-	}
-}
-
-func (g *Gomoku) Layout(_, _ int) (int, int) {
-	return screenWidth, screenHeight
-}
-
 // ai 计算落子 ----------------------------------------------------------------
 // https://github.com/lihongxun945/gobang 详细讲解AI算法过程,password: Gomoku_js#xxx
 const (
@@ -338,31 +321,71 @@ const (
 
 	scoreFiveNeg = float64(-scoreFive)
 	scoreMin     = scoreFiveNeg * 10
+
+	// 按节点数限制思考时,每秒大致能搜索的节点数,按普通电脑上连珠规则的速度估计,无禁手规则会快一些
+	searchNodesPerSec = 25000
 )
 
 func (g *Gomoku) ai() {
-	var (
-		x, y  int
-		score float64
-	)
 	for {
 		select {
 		case <-g.aiStatus:
-			for i := 2; i <= g.searchDeep; i += 2 {
-				x, y, score = g.maxMin(i)
-				if g.greatOrEqualThan(score, scoreFour) {
-					break // 所得分数大于阈值计算则不用继续搜索
+			g.resetSearch() // 开局阶段的评估不限时间,只在被取消时中止
+			if g.open.phase != phaseNone {
+				g.aiOpening() // 按开局规则完成电脑的操作,开局结束后轮到电脑时继续落子
+				if g.open.phase != phaseNone || !g.aiPlays(g.toMove()) {
+					g.aiStatus <- allNoneFlag
+					continue
 				}
 			}
-
-			g.put(x, y, comImgFlag) // 完成ai落子
-			if g.isWin(x, y, comImgFlag, comWinImgFlag) {
-				g.aiStatus <- statusBlackWin // AI赢了,设置状态
-			} else {
-				g.aiStatus <- allNoneFlag // AI还没赢,玩家可以继续落子
+			role := g.toMove()
+			x, y := g.aiMove(role)
+			if g.cancel.Load() {
+				g.aiStatus <- allNoneFlag // 已经重新开始或者关闭窗口,不再落子
+				continue
 			}
+
+			g.play(x, y, role)                 // 完成ai落子
+			g.aiStatus <- g.result(x, y, role) // 赢了时设置状态,否则轮到另一方落子
+		}
+	}
+}
+
+// 计算电脑为 role 一方的落子,不修改棋盘,思考时间不超过 g.moveTime 太多,被取消时结果不能使用
+func (g *Gomoku) aiMove(role int) (x, y int) {
+	begin := time.Now()
+	g.resetSearch()
+	if g.skill != nil {
+		return g.skillPoint(role) // 按棋力等级落子,不算杀,保留低等级的失误
+	}
+	x, y, ok := g.killWin(role)
+	if ok {
+		return
+	}
+	spent := g.killNodes
+
+	// 没有必胜时先排除挡不住对方算杀的落子
+	points := g.killDefend(g.gen(role), role)
+	spent += g.killNodes
+	// 逐层加深,第一层不限时保证有结果,之后超时的那一层结果不完整,使用上一层的
+	for i := 2; i <= g.searchDeep; i += 2 {
+		px, py, score := g.maxMin(i, points, role)
+		if g.searchStop {
+			break
+		}
+		x, y = px, py
+		if g.greatOrEqualThan(score, scoreFour) {
+			break // 所得分数大于阈值计算则不用继续搜索
+		}
+		if g.nodeBudget {
+			// 算杀用掉的节点按速度换算成时间,剩下的时间换算成搜索节点数
+			rest := g.moveTime - time.Duration(spent)*time.Second/killNodesPerSec
+			g.searchLimit = budgetNodes(rest, searchNodesPerSec)
+		} else {
+			g.deadline = begin.Add(g.moveTime)
 		}
 	}
+	return
 }
 
 // count 棋子数量,block 两边阻挡数量只能为[0,1,2],empty 棋子中间空位数量
@@ -567,6 +590,11 @@ func (g *Gomoku) mType(count, block, empty int) int {
 }
 
 func (g *Gomoku) scorePoint(px, py, role int) float64 {
+	if role == g.black && g.forbidden(px, py) {
+		// 黑方不能下在禁手点,对方也就不用防守这里,白方可以利用禁手点让黑方无法防守
+		return 0
+	}
+
 	var (
 		i, t, x, y, result  int
 		empty, count, block int
@@ -580,13 +608,13 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 
 	// 从px,py向右计算
 	for i = py + 1; true; i++ {
-		if i >= boardSize {
+		if i >= g.size {
 			block++ // 遇到右边界,算一个阻挡
 			break
 		}
 
 		if t = g.board[px][i]; t == allNoneFlag {
-			if empty == -1 && i < boardSize-1 && g.board[px][i+1] == role {
+			if empty == -1 && i < g.size-1 && g.board[px][i+1] == role {
 				// 首次遇到空位,且空位右边是role棋子能连上
 				// 此时empty为空位前面连续棋子数,例如 111_11 此时empty=3
 				// 会造成后续统计棋子数量时少了空位,这时候count-empty就是空位数量
@@ -634,17 +662,17 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 	}
 
 	// 将得分累加
-	result += g.mType(count, block, empty)
+	result += g.exactType(px, py, directions[0], role, g.mType(count, block, empty))
 
 	reset() // 从px,py下计算,过程同上
 	for i = px + 1; true; i++ {
-		if i >= boardSize {
+		if i >= g.size {
 			block++
 			break
 		}
 
 		if t = g.board[i][py]; t == allNoneFlag {
-			if empty == -1 && i < boardSize-1 && g.board[i+1][py] == role {
+			if empty == -1 && i < g.size-1 && g.board[i+1][py] == role {
 				empty = count
 				continue
 			} else {
@@ -685,18 +713,18 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 		}
 	}
 
-	result += g.mType(count, block, empty)
+	result += g.exactType(px, py, directions[1], role, g.mType(count, block, empty))
 
 	reset() // 从px,py向右下计算
 	for i = 1; true; i++ {
 		x, y = px+i, py+i
-		if x >= boardSize || y >= boardSize {
+		if x >= g.size || y >= g.size {
 			block++
 			break
 		}
 
 		if t = g.board[x][y]; t == allNoneFlag {
-			if empty == -1 && (x < boardSize-1 && y < boardSize-1) && g.board[x+1][y+1] == role {
+			if empty == -1 && (x < g.size-1 && y < g.size-1) && g.board[x+1][y+1] == role {
 				empty = count
 				continue
 			} else {
@@ -738,18 +766,18 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 		}
 	}
 
-	result += g.mType(count, block, empty)
+	result += g.exactType(px, py, directions[2], role, g.mType(count, block, empty))
 
 	reset() // 从px,py向左下计算
 	for i = 1; true; i++ {
 		x, y = px+i, py-i
-		if x >= boardSize || y < 0 {
+		if x >= g.size || y < 0 {
 			block++
 			break
 		}
 
 		if t = g.board[x][y]; t == allNoneFlag {
-			if empty == -1 && (x < boardSize-1 && y > 0) && g.board[x+1][y-1] == role {
+			if empty == -1 && (x < g.size-1 && y > 0) && g.board[x+1][y-1] == role {
 				empty = count
 				continue
 			} else {
@@ -767,13 +795,13 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 	// 从px,py向右上计算
 	for i = 1; true; i++ {
 		x, y = px-i, py+i
-		if x < 0 || y >= boardSize {
+		if x < 0 || y >= g.size {
 			block++
 			break
 		}
 
 		if t = g.board[x][y]; t == allNoneFlag {
-			if empty == -1 && (x > 0 && y < boardSize-1) && g.board[x-1][y+1] == role {
+			if empty == -1 && (x > 0 && y < g.size-1) && g.board[x-1][y+1] == role {
 				empty = 0
 				continue
 			} else {
@@ -792,10 +820,13 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 	}
 
 	// 当前位置在所有方向上分数全部累加,综合分数高最优先落子
-	result += g.mType(count, block, empty)
+	result += g.exactType(px, py, directions[3], role, g.mType(count, block, empty))
 
 	// 只做一件事,就是修复冲四
 	if result < scoreFour && result >= scoreBlockedFour {
+		if g.fourUnstoppable(px, py, role) {
+			return scoreFour // 连珠规则黑方只能下在禁手点防守,白方冲四相当于活四
+		}
 		if result >= scoreBlockedFour && result < (scoreBlockedFour+scoreThree) {
 			return scoreThree // 单独冲四,意义不大,则将分数降至活三
 		} else if result >= scoreBlockedFour+scoreThree && result < scoreBlockedFour*2 {
@@ -807,8 +838,23 @@ func (g *Gomoku) scorePoint(px, py, role int) float64 {
 	return float64(result)
 }
 
-// 启发函数
-func (g *Gomoku) gen() [][]int {
+// 启发函数,返回 role 可以落子的位置,置换表中记录的最佳落子排在最前面,让剪枝更早发生
+func (g *Gomoku) gen(role int) [][]int {
+	points := g.candidates(role)
+	if e := g.tt.probe(g.zobristCode, role); e != nil && e.move != noMove {
+		for i, p := range points {
+			if p[0] == int(e.move[0]) && p[1] == int(e.move[1]) {
+				copy(points[1:i+1], points[:i])
+				points[0] = p
+				break
+			}
+		}
+	}
+	return points
+}
+
+// 按棋型给 role 可以落子的位置分类排序,连珠规则黑方不能下在禁手点
+func (g *Gomoku) candidates(role int) [][]int {
 	var (
 		fives        [][]int
 		fours        [][]int
@@ -819,35 +865,40 @@ func (g *Gomoku) gen() [][]int {
 		neighbors    [][]int
 	)
 
-	for i := 0; i < boardSize; i++ {
-		for j := 0; j < boardSize; j++ {
+	mine, theirs := &g.comScore, &g.humScore // role 自己和对方的分数
+	if role == humImgFlag {
+		mine, theirs = theirs, mine
+	}
+
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
 			// 当前位置没有落子,且该位置有邻居,对于那些周围没有棋子的空位判断时没意义
-			if g.hasNeighbor(i, j) {
-				switch scoreHum, scoreCom := g.humScore[i][j], g.comScore[i][j]; {
-				case scoreCom >= scoreFive:
-					return [][]int{{i, j}} // 先看电脑能不能连成5
-				case scoreHum >= scoreFive:
-					// 再看玩家能不能连成5,别急着返回,因为遍历还没完成,说不定电脑自己能成五
+			if g.hasNeighbor(i, j) && (role != g.black || !g.forbidden(i, j)) {
+				switch scoreMine, scoreTheirs := mine[i][j], theirs[i][j]; {
+				case scoreMine >= scoreFive:
+					return [][]int{{i, j}} // 先看自己能不能连成5
+				case scoreTheirs >= scoreFive:
+					// 再看对方能不能连成5,别急着返回,因为遍历还没完成,说不定自己能成五
 					fives = append(fives, []int{i, j})
-				case scoreCom >= scoreFour:
-					fours = append([][]int{{i, j}}, fours...) // 对电脑有利放前面
-				case scoreHum >= scoreFour:
-					fours = append(fours, []int{i, j}) // 对玩家有利放后面,下面同理
-				case scoreCom >= scoreBlockedFour:
+				case scoreMine >= scoreFour:
+					fours = append([][]int{{i, j}}, fours...) // 对自己有利放前面
+				case scoreTheirs >= scoreFour:
+					fours = append(fours, []int{i, j}) // 对对方有利放后面,下面同理
+				case scoreMine >= scoreBlockedFour:
 					blockedFours = append([][]int{{i, j}}, blockedFours...)
-				case scoreHum >= scoreBlockedFour:
+				case scoreTheirs >= scoreBlockedFour:
 					blockedFours = append(blockedFours, []int{i, j})
-				case scoreCom >= 2*scoreThree: // 能成双三也行
+				case scoreMine >= 2*scoreThree: // 能成双三也行
 					twoThrees = append([][]int{{i, j}}, twoThrees...)
-				case scoreHum >= 2*scoreThree:
+				case scoreTheirs >= 2*scoreThree:
 					twoThrees = append(twoThrees, []int{i, j})
-				case scoreCom >= scoreThree:
+				case scoreMine >= scoreThree:
 					threes = append([][]int{{i, j}}, threes...)
-				case scoreHum >= scoreThree:
+				case scoreTheirs >= scoreThree:
 					threes = append(threes, []int{i, j})
-				case scoreCom >= scoreTwo:
+				case scoreMine >= scoreTwo:
 					twos = append([][]int{{i, j}}, twos...)
-				case scoreHum >= scoreTwo:
+				case scoreTheirs >= scoreTwo:
 					twos = append(twos, []int{i, j})
 				default:
 					neighbors = append(neighbors, []int{i, j})
@@ -890,8 +941,8 @@ func (g *Gomoku) evaluate(role int) float64 {
 	comMaxScore, humMaxScore := scoreFiveNeg, scoreFiveNeg
 
 	// 遍历出最高分,开销不大
-	for i := 0; i < boardSize; i++ {
-		for j := 0; j < boardSize; j++ {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
 			if g.board[i][j] == allNoneFlag {
 				if g.comScore[i][j] > comMaxScore {
 					comMaxScore = g.comScore[i][j]
@@ -909,20 +960,53 @@ func (g *Gomoku) evaluate(role int) float64 {
 	return humMaxScore - comMaxScore
 }
 
+// 搜索或者算杀的节点数 nodes 是否超出限制,limit 大于0时按节点数,否则按截止时间,截止时间为零值时不限
+func overBudget(nodes, limit int, deadline time.Time) bool {
+	if limit > 0 {
+		return nodes >= limit
+	}
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// d 时间大致能搜索的节点数,rate 为每秒节点数,至少为1,时间已经用完时下一次检查就会中止
+func budgetNodes(d time.Duration, rate int) int {
+	return max(1, int(d*time.Duration(rate)/time.Second))
+}
+
+// 清除上一次搜索的节点数,截止时间和节点数上限,之后的搜索不限时间,直到再次设置
+func (g *Gomoku) resetSearch() {
+	g.deadline, g.searchLimit, g.searchNodes, g.searchStop = time.Time{}, 0, 0, false
+}
+
+// 每隔一段节点检查一次是否超时或者被取消,中止后所有搜索立即返回,结果不能使用
+func (g *Gomoku) searchTimeout() bool {
+	if g.searchNodes++; g.cancel.Load() ||
+		g.searchNodes%1024 == 0 && overBudget(g.searchNodes, g.searchLimit, g.deadline) {
+		g.searchStop = true
+	}
+	return g.searchStop
+}
+
 func (g *Gomoku) max(deep, role int, alpha, beta float64) float64 {
+	if g.searchTimeout() {
+		return 0
+	}
+
 	var v float64
-	c, ok := g.cache[g.zobristCode]
-	if ok {
-		if c.deep >= deep {
-			return c.score // 得到更深层次相同局面,直接返回分数
+	if e := g.tt.probe(g.zobristCode, role); e != nil {
+		if int(e.depth) >= deep {
+			switch {
+			case e.flag == ttExact, e.flag == ttLower && e.score >= beta, e.flag == ttUpper && e.score <= alpha:
+				return e.score // 得到更深层次相同局面的准确分数,或者足以剪枝的边界
+			}
 		}
-		v = c.maxScore // 得到该状态下最大值,无需重复计算
+		v = e.eval // 得到该状态下最大值,无需重复计算
 	} else {
 		v = g.evaluate(role)
-		c = &gomokuCache{maxScore: v}
 	}
 
 	if deep <= 0 || g.greatOrEqualThan(v, scoreFive) {
+		g.tt.store(g.zobristCode, role, 0, ttExact, v, v, noMove)
 		return v // 到达深度或棋面估分大于连五,直接返回
 	}
 
@@ -940,8 +1024,10 @@ func (g *Gomoku) max(deep, role int, alpha, beta float64) float64 {
 	}
 
 	var (
-		best   = scoreMin
-		points = g.gen()
+		eval     = v
+		best     = scoreMin
+		bestMove = noMove
+		points   = g.gen(role)
 	)
 	for _, p := range points {
 		g.put(p[0], p[1], role) // 假设role棋子落下
@@ -950,40 +1036,50 @@ func (g *Gomoku) max(deep, role int, alpha, beta float64) float64 {
 
 		g.remove(p[0], p[1]) // 取消role落子
 
-		if g.greatOrEqualThan(v, beta) { // AB 剪枝
-			c.deep, c.score = deep, v
-			g.cache[g.zobristCode] = c
+		if g.searchStop {
+			return 0 // 中止时分数不完整,不写入置换表
+		}
+		if g.greatOrEqualThan(v, beta) { // AB 剪枝,分数只是下界
+			g.tt.store(g.zobristCode, role, deep, ttLower, v, eval, [2]int8{int8(p[0]), int8(p[1])})
 			return v
 		}
 		if g.greatThan(v, best) {
-			best = v
+			best, bestMove = v, [2]int8{int8(p[0]), int8(p[1])}
 		}
 	}
-	// todo 可以计算特殊算杀逻辑
+	// 算杀在 maxMin 之前由 killWin 和 killDefend 完成,见 vcf.go
 
-	c.deep, c.score = deep, best
-	g.cache[g.zobristCode] = c
+	flag := ttExact
+	if best <= alpha {
+		flag = ttUpper // 没有超过 alpha 的落子,分数只是上界
+	}
+	g.tt.store(g.zobristCode, role, deep, flag, best, eval, bestMove)
 	return best
 }
 
-func (g *Gomoku) maxMin(deep int) (x, y int, score float64) {
+// 从 points 中选择 role 的落子,points 一般是 g.gen(role)
+func (g *Gomoku) maxMin(deep int, points [][]int, role int) (x, y int, score float64) {
+	if len(points) == 0 {
+		x, y, _ = g.anyPoint(role) // 附近的位置都是禁手点,调用方已经确认还有可以落子的位置
+		return x, y, scoreMin
+	}
+
 	var (
 		best       = scoreMin
-		points     = g.gen()
 		bestPoints [][]int
 	)
 	for _, p := range points {
-		g.put(p[0], p[1], comImgFlag) // 电脑先手
+		g.put(p[0], p[1], role) // role 先落子
 
-		// 进入极大值递归算法,这次玩家后手
-		v := -g.max(deep-1, humImgFlag, scoreMin, -best)
+		// 进入极大值递归算法,这次对方后手
+		v := -g.max(deep-1, opponent(role), scoreMin, -best)
 
-		g.remove(p[0], p[1]) // 去掉电脑落子
+		g.remove(p[0], p[1]) // 去掉 role 落子
 
-		// 边缘棋子的话,要把分数打折,避免电脑总喜欢往边上走
-		if p[0] < 3 || p[0] > 11 || p[1] < 3 || p[1] > 11 {
-			v = 0.5 * v
+		if g.searchStop {
+			return 0, 0, best // 中止时结果不完整,由调用方丢弃
 		}
+		v = g.edgeScore(p, v)
 
 		if g.greatThan(v, best) {
 			best = v // 找到一个更好的分,清除之前结果
@@ -993,11 +1089,19 @@ func (g *Gomoku) maxMin(deep int) (x, y int, score float64) {
 			bestPoints = append(bestPoints, p)
 		}
 	}
-	// 按照正常情况 len(bestPoints) > 0,随机选择位置,避免被发现规律
-	p := bestPoints[rand.Intn(len(bestPoints))]
+	// points 不为空时 len(bestPoints) > 0,随机选择位置,避免被发现规律
+	p := bestPoints[g.rand.Intn(len(bestPoints))]
 	return p[0], p[1], best
 }
 
+// 边缘棋子的话,要把分数打折,避免电脑总喜欢往边上走
+func (g *Gomoku) edgeScore(p []int, v float64) float64 {
+	if n := g.size - 4; p[0] < 3 || p[0] > n || p[1] < 3 || p[1] > n {
+		return 0.5 * v
+	}
+	return v
+}
+
 // 只更新一个点附近的分数
 func (g *Gomoku) updateScore(x, y int) {
 	update := func(x, y int) {
@@ -1011,7 +1115,7 @@ func (g *Gomoku) updateScore(x, y int) {
 	// - 左右计算
 	for i = -radius; i < radius; i++ {
 		if tx, ty = x, y+i; ty >= 0 {
-			if ty >= boardSize {
+			if ty >= g.size {
 				break
 			}
 			if g.board[tx][ty] == allNoneFlag {
@@ -1023,7 +1127,7 @@ func (g *Gomoku) updateScore(x, y int) {
 	// | 上下计算
 	for i = -radius; i < radius; i++ {
 		if tx, ty = x+i, y; tx >= 0 {
-			if tx >= boardSize {
+			if tx >= g.size {
 				break
 			}
 			if g.board[tx][ty] == allNoneFlag {
@@ -1035,7 +1139,7 @@ func (g *Gomoku) updateScore(x, y int) {
 	// \ 左上右下计算
 	for i = -radius; i < radius; i++ {
 		if tx, ty = x+i, y+i; tx >= 0 && ty >= 0 {
-			if tx >= boardSize || ty >= boardSize {
+			if tx >= g.size || ty >= g.size {
 				break
 			}
 			if g.board[tx][ty] == allNoneFlag {
@@ -1047,7 +1151,7 @@ func (g *Gomoku) updateScore(x, y int) {
 	// / 左下右上计算
 	for i = -radius; i < radius; i++ {
 		if tx, ty = x+i, y-i; tx >= 0 && ty >= 0 {
-			if tx >= boardSize || ty >= boardSize {
+			if tx >= g.size || ty >= g.size {
 				break // todo 验证有没有问题
 			}
 			if g.board[tx][ty] == allNoneFlag {
@@ -1063,9 +1167,9 @@ func (g *Gomoku) hasNeighbor(x, y int) bool {
 		sx, ex := x-2, x+2
 		sy, ey := y-2, y+2
 		for i := sx; i <= ex; i++ {
-			if i >= 0 && i < boardSize {
+			if i >= 0 && i < g.size {
 				for j := sy; j <= ey; j++ {
-					if j >= 0 && j < boardSize && (i != x || j != y) &&
+					if j >= 0 && j < g.size && (i != x || j != y) &&
 						g.board[i][j] != allNoneFlag {
 						return true // 当前x,y位置是空位,周围2格至少存在1个棋子
 					}
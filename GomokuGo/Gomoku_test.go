@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试用的对局,种子固定,每一步最多思考 100ms
+func newTestGomoku(t *testing.T, rule int) *Gomoku {
+	t.Helper()
+	var (
+		seed, elo = int64(1), 0
+		name      = ruleNames[rule]
+		moveTime  = 100 * time.Millisecond
+	)
+	g := (&gameFlags{seed: &seed, elo: &elo, rule: &name, moveTime: &moveTime}).newGomoku()
+	g.size = minBoardSize
+	g.reset()
+	return g
+}
+
+func TestResultDraw(t *testing.T) {
+	g := newTestGomoku(t, ruleFreestyle)
+	// 每行按两个一组交替,横竖斜都没有连五,电脑一方多一个棋子,作为先手方
+	role := func(i, j int) int { return 1 + (i/2+j)%2 }
+	g.black = comImgFlag
+	last := [2]int{g.size - 1, g.size - 1}
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if [2]int{i, j} != last {
+				g.put(i, j, role(i, j))
+			}
+		}
+	}
+
+	r := role(last[0], last[1])
+	if _, _, ok := g.anyPoint(r); !ok {
+		t.Fatal("no point left before the last stone")
+	}
+	g.put(last[0], last[1], r)
+	if s := g.result(last[0], last[1], r); s != statusDraw {
+		t.Fatalf("full board status %d, want draw", s)
+	}
+}
+
+func TestAIMoveWithoutCandidates(t *testing.T) {
+	// 空棋盘上没有棋子附近的候选点,电脑也要能落子
+	for _, elo := range []int{0, 1100} {
+		g := newTestGomoku(t, ruleFreestyle)
+		g.skill = findSkill(elo)
+		x, y := g.aiMove(comImgFlag)
+		if x < 0 || y < 0 || x >= g.size || y >= g.size || g.board[x][y] != allNoneFlag {
+			t.Fatalf("elo %d: invalid move %d,%d", elo, x, y)
+		}
+	}
+}
+
+func TestSeededAIReproducible(t *testing.T) {
+	// 指定种子时按节点数限制思考,相同种子走出的棋一样,和机器快慢无关
+	play := func() (moves [][2]int) {
+		g := newTestGomoku(t, ruleRenju)
+		g.black = comImgFlag
+		g.play(g.size/2, g.size/2, comImgFlag)
+		for role := humImgFlag; len(g.moves) < 8; role = opponent(role) {
+			x, y := g.aiMove(role)
+			g.play(x, y, role)
+		}
+		return g.moves
+	}
+	a, b := play(), play()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("move %d differs: %v and %v", i+1, a, b)
+		}
+	}
+}
+
+func TestAIOpeningAfterMove(t *testing.T) {
+	// 上一局电脑落子时设置的截止时间和节点数上限,不能让开局阶段的评估提前中止
+	g := newTestGomoku(t, ruleRenju)
+	g.opening = openSoosorv
+	g.play(g.size/2, g.size/2, humImgFlag)
+	g.aiMove(comImgFlag)
+	time.Sleep(g.moveTime)
+
+	go g.ai()
+	if g.start(comImgFlag) {
+		g.aiStatus <- 0
+	}
+	<-g.aiStatus
+	if g.searchStop || g.open.actor != humImgFlag || g.count() != 3 {
+		t.Fatalf("opening stopped after %d nodes, %d stones", g.searchNodes, g.count())
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gomocup 对弈协议,见 https://plastovicka.github.io/protocl2en.htm
+// 管理程序每行发一个命令,需要落子时回复 "x,y",电脑是 comImgFlag,对手是 humImgFlag,x,y 对应 board[x][y]
+// 界面版本用 -brain 参数运行,没有显示器的机器上用 go build -tags nogui 编译,不包含界面,见 brain_main.go
+const (
+	brainAbout  = `name="LittleGame Gomoku", version="1.0", author="jan-bar"`
+	brainMargin = 50 * time.Millisecond // 管理程序按墙上时间计时,留出读写命令的余量
+)
+
+// 管理程序 INFO rule 的位标记
+const (
+	gomocupExact = 1 // 只有正好五连才算赢
+	gomocupRenju = 4 // 连珠规则
+)
+
+// 按命令行参数创建对局,从标准输入读取 Gomocup 命令,出错时退出
+func (f *gameFlags) runBrain() {
+	if err := f.newGomoku().brain(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// 作为 Gomocup 引擎运行,直到收到 END 或者输入结束
+func (g *Gomoku) brain(r io.Reader, w io.Writer) error {
+	var (
+		sc       = bufio.NewScanner(r)
+		started  bool
+		turnTime = g.moveTime // 每一步的时间上限,管理程序没有发 INFO timeout_turn 时使用 -time
+		timeLeft time.Duration
+	)
+	reply := func(format string, a ...any) {
+		fmt.Fprintf(w, format+"\n", a...)
+	}
+	move := func() {
+		if _, _, ok := g.anyPoint(comImgFlag); !ok {
+			reply("ERROR no legal move, the board is full or only forbidden points are left")
+			return
+		}
+		x, y := g.size/2, g.size/2 // 空棋盘下在正中央
+		if g.count() > 0 {
+			budget := turnTime
+			if timeLeft > 0 {
+				budget = min(budget, timeLeft/10) // 整局剩余时间不多时每一步少用一些
+			}
+			g.moveTime = max(budget*9/10-brainMargin, budget/2)
+			x, y = g.aiMove(comImgFlag)
+		}
+		g.put(x, y, comImgFlag)
+		reply("%d,%d", x, y)
+	}
+
+	for sc.Scan() {
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		cmd = strings.ToUpper(cmd)
+		switch {
+		case cmd == "":
+			continue
+		case !started && cmd != "START" && cmd != "INFO" && cmd != "ABOUT" && cmd != "END":
+			reply("ERROR expected START")
+			continue
+		}
+
+		switch cmd {
+		case "START":
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || n < minBoardSize || n > maxBoardSize {
+				reply("ERROR unsupported board size %s, only %d to %d", arg, minBoardSize, maxBoardSize)
+				continue
+			}
+			g.size = n
+			g.reset()
+			started = true
+			reply("OK")
+		case "RESTART":
+			g.reset()
+			reply("OK")
+		case "BEGIN":
+			g.black = comImgFlag // 电脑先手
+			move()
+		case "TURN":
+			x, y, err := g.brainPoint(arg)
+			if err != nil {
+				reply("ERROR %v", err)
+				continue
+			}
+			g.put(x, y, humImgFlag)
+			move()
+		case "BOARD":
+			if err := g.brainBoard(sc); err != nil {
+				reply("ERROR %v", err)
+				continue
+			}
+			move()
+		case "INFO":
+			key, val, _ := strings.Cut(strings.TrimSpace(arg), " ")
+			g.brainInfo(key, val, &turnTime, &timeLeft)
+		case "END":
+			return nil
+		case "ABOUT":
+			reply(brainAbout)
+		default:
+			reply("UNKNOWN command %s", cmd)
+		}
+	}
+	return sc.Err()
+}
+
+// 解析 "x,y" 形式的空位坐标
+func (g *Gomoku) brainPoint(s string) (x, y int, err error) {
+	xs, ys, ok := strings.Cut(strings.TrimSpace(s), ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid point %q", s)
+	}
+	if x, err = strconv.Atoi(strings.TrimSpace(xs)); err == nil {
+		y, err = strconv.Atoi(strings.TrimSpace(ys))
+	}
+	switch {
+	case err != nil:
+		return 0, 0, fmt.Errorf("invalid point %q", s)
+	case x < 0 || y < 0 || x >= g.size || y >= g.size:
+		return 0, 0, fmt.Errorf("point %d,%d is out of the board", x, y)
+	case g.board[x][y] != allNoneFlag:
+		return 0, 0, fmt.Errorf("point %d,%d is not empty", x, y)
+	}
+	return x, y, nil
+}
+
+// 读取 BOARD 命令之后到 DONE 为止的棋子,每行 "x,y,who",who 为1是自己的棋子,2是对手的,3是连续对局的胜利棋子
+// 之后轮到电脑落子,双方棋子一样多时电脑是先手方
+func (g *Gomoku) brainBoard(sc *bufio.Scanner) error {
+	g.reset()
+	var (
+		err      error
+		com, hum int
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.EqualFold(line, "DONE") {
+			break
+		}
+		f := strings.Split(line, ",")
+		if err != nil {
+			continue // 出错后仍然读到 DONE 为止
+		}
+		if len(f) != 3 {
+			err = fmt.Errorf("invalid board line %q", line)
+			continue
+		}
+
+		var x, y int
+		if x, y, err = g.brainPoint(f[0] + "," + f[1]); err != nil {
+			continue
+		}
+		switch strings.TrimSpace(f[2]) {
+		case "1":
+			g.put(x, y, comImgFlag)
+			com++
+		case "2":
+			g.put(x, y, humImgFlag)
+			hum++
+		case "3":
+		default:
+			err = fmt.Errorf("invalid board line %q", line)
+		}
+	}
+	if com == hum {
+		g.black = comImgFlag
+	}
+	return err
+}
+
+// 处理 INFO 命令,不支持的项忽略
+func (g *Gomoku) brainInfo(key, val string, turnTime, timeLeft *time.Duration) {
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		return
+	}
+	switch strings.ToLower(key) {
+	case "timeout_turn":
+		*turnTime = time.Duration(n) * time.Millisecond // 0 表示尽快落子
+	case "time_left":
+		*timeLeft = time.Duration(n) * time.Millisecond
+	case "rule":
+		rule := ruleFreestyle
+		if n&gomocupRenju != 0 {
+			rule = ruleRenju
+		} else if n&gomocupExact != 0 {
+			rule = ruleStandard
+		}
+		if rule != g.rule {
+			g.rule = rule
+			g.tt.clear() // 分数和规则有关
+		}
+		log.Printf("rule: %s", ruleNames[rule])
+	}
+}
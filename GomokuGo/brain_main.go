@@ -0,0 +1,20 @@
+//go:build nogui
+
+package main
+
+import "flag"
+
+// 不带界面的 Gomocup 引擎,不依赖 ebiten,可以在没有显示器的机器上运行
+// go build -tags nogui -o pbrain-littlegame,总是作为引擎运行,-brain 参数可以省略
+func main() {
+	gf := newGameFlags()
+	flag.Parse()
+	if *gf.selfPlay > 0 {
+		gf.runSelfPlay()
+		return
+	}
+	gf.runBrain()
+}
+
+// 界面版本才有的数据,这里为空
+type view struct{}
@@ -0,0 +1,72 @@
+package main
+
+// 对局中真正的落子,记录落子顺序,之后不能再恢复之前悔棋撤销的落子
+// 搜索中假设的落子直接用 put 和 remove
+func (g *Gomoku) play(x, y, role int) {
+	g.put(x, y, role)
+	g.moves = append(g.moves, [2]int{x, y})
+	g.redo = g.redo[:0]
+}
+
+// 悔棋,撤销玩家最后一次落子和之后电脑的落子,双人对弈时只撤销一手,开局规则摆放的棋子不能撤销
+// 通过 remove 撤销,棋盘,zobrist code 和双方分数都回到玩家落子之前
+func (g *Gomoku) undo() {
+	last := -1
+	for i := len(g.moves) - 1; i >= g.open.fixed && last < 0; i-- {
+		if !g.aiPlays(sideOf(g.board[g.moves[i][0]][g.moves[i][1]])) {
+			last = i
+		}
+	}
+	if last < 0 {
+		return // 没有可以撤销的玩家落子
+	}
+	g.clearWin()
+
+	for i := len(g.moves) - 1; i >= last; i-- {
+		p := g.moves[i]
+		g.redo = append(g.redo, [3]int{p[0], p[1], sideOf(g.board[p[0]][p[1]])})
+		g.remove(p[0], p[1])
+	}
+	g.moves = g.moves[:last]
+	g.status = allNoneFlag
+}
+
+// 恢复上一次悔棋撤销的玩家落子和电脑落子,恢复到分出胜负时停止
+func (g *Gomoku) redoMove() {
+	for len(g.redo) > 0 && g.status == allNoneFlag {
+		m := g.redo[len(g.redo)-1]
+		g.redo = g.redo[:len(g.redo)-1]
+		g.put(m[0], m[1], m[2])
+		g.moves = append(g.moves, [2]int{m[0], m[1]})
+
+		g.status = g.result(m[0], m[1], m[2])
+		if len(g.redo) == 0 || !g.aiPlays(g.redo[len(g.redo)-1][2]) {
+			break // 下一手是玩家的,这次悔棋已经全部恢复
+		}
+	}
+}
+
+// 赢了的棋子属于哪一方
+func sideOf(r int) int {
+	switch r {
+	case humWinImgFlag:
+		return humImgFlag
+	case comWinImgFlag:
+		return comImgFlag
+	}
+	return r
+}
+
+// 分出胜负时连五的棋子换成了赢了的图片,悔棋前换回普通棋子
+func (g *Gomoku) clearWin() {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			switch g.board[i][j] {
+			case humWinImgFlag:
+				g.board[i][j] = humImgFlag
+			case comWinImgFlag:
+				g.board[i][j] = comImgFlag
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "github.com/jan-bar/LittleGame/internal/locale"
+
+// 界面文字编号,对应 langText 中的翻译
+const (
+	msgTitle       = iota // 窗口标题
+	msgHelp               // 操作提示
+	msgHelpHuman          // 双人对弈的操作提示
+	msgHelpAuto           // 电脑对弈电脑的操作提示
+	msgAIThink            // 电脑思考中
+	msgWhiteWin           // 白棋胜
+	msgBlackWin           // 黑棋胜
+	msgDraw               // 和棋
+	msgBlackTurn          // 轮到黑棋
+	msgWhiteTurn          // 轮到白棋
+	msgResume             // 询问是否继续上次的对局
+	msgOpenPropose        // 摆放开局前3手
+	msgOpenChoose         // 选择先后手
+	msgOpenSwap2          // swap2 选择先后手或者再下两手
+	msgOpenAddTwo         // swap2 再下两手
+	msgOpenFourth         // 索索夫下第4手
+	msgOpenDeclare        // 索索夫声明打点数量
+	msgOpenOffer          // 索索夫摆放打点
+	msgOpenPick           // 索索夫选择打点
+	msgComFirst           // 电脑选择执先手
+	msgComSecond          // 电脑选择执后手
+	msgLength             // 文字总数
+)
+
+var (
+	langText = map[string]*[msgLength]string{
+		"en": {
+			msgTitle:     "Gomoku Golang",
+			msgHelp:      "press B to play black (first),W to play white,U to undo,R to redo,M to show move numbers",
+			msgHelpHuman: "press B or W to restart,U to undo,R to redo,M to show move numbers",
+			msgHelpAuto:  "press B or W to restart,M to show move numbers",
+			msgAIThink:   "AI is thinking, please wait!",
+			msgWhiteWin:  "White has won, please restart the game!",
+			msgBlackWin:  "Black has won, please restart the game!",
+			msgDraw:      "Draw, no legal move left, please restart the game!",
+			msgBlackTurn: "Black to move",
+			msgWhiteTurn: "White to move",
+			msgResume:    "Resume the last game? Y: Yes  N: No",
+
+			msgOpenPropose: "Place the opening stones, %d left",
+			msgOpenChoose:  "1: play first  2: play second",
+			msgOpenSwap2:   "1: first  2: second  3: add two stones",
+			msgOpenAddTwo:  "Place two more stones, %d left",
+			msgOpenFourth:  "Place the 4th stone",
+			msgOpenDeclare: "Press 1-%d: number of 5th move offers",
+			msgOpenOffer:   "Place 5th move offers, %d left",
+			msgOpenPick:    "Click the 5th move offer to keep",
+			msgComFirst:    "The computer plays first",
+			msgComSecond:   "The computer plays second",
+		},
+		"zh": {
+			msgTitle:     "五子棋",
+			msgHelp:      "按B键执黑先手,按W键执白后手,按U键悔棋,按R键恢复悔棋,按M键显示手数",
+			msgHelpHuman: "按B或W键重新开始,按U键悔棋,按R键恢复悔棋,按M键显示手数",
+			msgHelpAuto:  "按B或W键重新开始,按M键显示手数",
+			msgAIThink:   "电脑思考中,请稍候!",
+			msgWhiteWin:  "白棋胜,请重新开始!",
+			msgBlackWin:  "黑棋胜,请重新开始!",
+			msgDraw:      "和棋,已经没有可以落子的位置,请重新开始!",
+			msgBlackTurn: "轮到黑棋",
+			msgWhiteTurn: "轮到白棋",
+			msgResume:    "继续上次的对局? Y键继续 N键新开",
+
+			msgOpenPropose: "摆放开局,还剩%d手",
+			msgOpenChoose:  "1键执先手 2键执后手",
+			msgOpenSwap2:   "1键执先手 2键执后手 3键再下两手",
+			msgOpenAddTwo:  "再下两手,还剩%d手",
+			msgOpenFourth:  "下第4手",
+			msgOpenDeclare: "按1-%d键声明第5手打点数量",
+			msgOpenOffer:   "摆放第5手打点,还剩%d个",
+			msgOpenPick:    "点击要保留的第5手打点",
+			msgComFirst:    "电脑执先手",
+			msgComSecond:   "电脑执后手",
+		},
+	}
+	// 当前使用的语言
+	lang = langText["en"]
+)
+
+// 设置界面语言,name为空时根据系统区域设置选择
+func setLang(name string) {
+	lang = langText[locale.Pick(name)]
+}
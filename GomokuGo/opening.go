@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// 开局规则,先手优势太大时用来平衡双方
+const (
+	openFree    = iota // 无开局规则,按B键玩家执黑先手,按W键电脑执黑先手在正中央落子
+	openSwap           // 一方摆放前3手,另一方选择执先手还是后手
+	openSwap2          // 同上,另一方还可以再下第4,5手,由摆放方选择先后手
+	openSoosorv        // 索索夫: 前3手同上,执后手的一方下第4手并声明第5手打点数量,先手方可以再次交换,然后打点由后手方选择
+)
+
+var openingNames = [...]string{
+	openFree:    "free",
+	openSwap:    "swap",
+	openSwap2:   "swap2",
+	openSoosorv: "soosorv",
+}
+
+// 根据名称查找开局规则
+func findOpening(name string) (int, error) {
+	return findName("opening", openingNames[:], name)
+}
+
+// 开局阶段
+const (
+	phaseNone    = iota // 开局已经结束,双方轮流落子
+	phasePropose        // 摆放前3手
+	phaseChoose         // 选择执先手或者后手,swap2 还可以选择再下两手
+	phaseAddTwo         // swap2 再下第4,5手
+	phaseChoose2        // swap2 摆放方选择执先手或者后手
+	phaseFourth         // 索索夫后手方下第4手
+	phaseDeclare        // 索索夫后手方声明第5手打点数量
+	phaseSwap4          // 索索夫先手方选择是否交换
+	phaseOffer          // 索索夫先手方摆放第5手打点
+	phasePick           // 索索夫后手方选择保留一个打点
+)
+
+const (
+	maxOffers    = 8            // 索索夫第5手打点数量上限
+	openSamples  = 24           // 电脑摆放开局时尝试的随机摆法数量
+	openDepth    = 4            // 开局评估的搜索深度,用偶数层,奇数层时最后落子的一方分数偏高
+	openBalanced = scoreTwo * 3 // swap2 局面差距在此范围内时电脑选择再下两手
+)
+
+// 开局阶段的状态,ai 协程中也会修改,主线程在 ai 空闲时复制到 showOpen 用于显示
+type openState struct {
+	phase   int      // 当前阶段
+	actor   int      // 当前阶段由哪一方操作
+	offers  int      // 索索夫声明的第5手打点数量
+	offered [][2]int // 已经摆放的第5手打点
+	notice  int      // 电脑选择先后手后显示的提示,0表示不显示
+	fixed   int      // 开局规则摆放的棋子数量,开局结束后这些棋子不能悔棋
+}
+
+func opponent(role int) int {
+	if role == comImgFlag {
+		return humImgFlag
+	}
+	return comImgFlag
+}
+
+// 开始新的一局,first 为先手方,有开局规则时由 first 摆放开局
+// 返回true表示轮到电脑操作
+func (g *Gomoku) start(first int) (sendAI bool) {
+	g.reset()
+	g.black = first
+	if g.opening == openFree {
+		if g.aiPlays(first) {
+			g.play(g.size/2, g.size/2, first) // 电脑先手,正中央下黑棋
+		}
+		return g.comTurn()
+	}
+	g.open = openState{phase: phasePropose, actor: first}
+	return g.comTurn()
+}
+
+// 棋盘上的棋子数量
+func (g *Gomoku) count() (n int) {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if g.board[i][j] != allNoneFlag {
+				n++
+			}
+		}
+	}
+	return
+}
+
+// 按棋子数量判断轮到哪一方落子,一样多时轮到先手方
+func (g *Gomoku) toMove() int {
+	if g.count()%2 == 0 {
+		return g.black
+	}
+	return opponent(g.black)
+}
+
+// 开局规则的操作或者落子完成后,轮到电脑时设置状态并返回true
+func (g *Gomoku) comTurn() bool {
+	if o := &g.open; o.phase != phaseNone && o.actor == comImgFlag ||
+		o.phase == phaseNone && g.aiPlays(g.toMove()) {
+		g.status = statusComputerRun
+		return true
+	}
+	return false
+}
+
+// 交换双方的棋子,选择先后手时让选择的一方拥有对应的棋子
+func (g *Gomoku) swapSides() {
+	var stones [][3]int
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if r := g.board[i][j]; r != allNoneFlag {
+				stones = append(stones, [3]int{i, j, r})
+				g.remove(i, j)
+			}
+		}
+	}
+	for _, s := range stones {
+		g.put(s[0], s[1], opponent(s[2]))
+	}
+	g.black = opponent(g.black)
+	g.tt.clear() // 先手方变了,分数也不一样了
+}
+
+// 开局阶段的下一手棋子属于哪一方,打点都是先手方的棋子,其余按棋子数量轮流
+func (g *Gomoku) stoneRole() int {
+	if g.open.phase == phaseOffer {
+		return g.black
+	}
+	return g.toMove()
+}
+
+// x,y 能否作为开局阶段的下一手,打点不能和已有的打点对称
+func (g *Gomoku) canPlace(x, y int) bool {
+	if g.board[x][y] != allNoneFlag || g.stoneRole() == g.black && g.forbidden(x, y) {
+		return false
+	}
+	if g.open.phase == phaseOffer {
+		for _, p := range g.open.offered {
+			if g.symmetric(p, [2]int{x, y}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 开局阶段落子,摆够数量后进入下一阶段
+func (g *Gomoku) place(x, y int) {
+	o := &g.open
+	g.play(x, y, g.stoneRole())
+	switch n := g.count(); o.phase {
+	case phasePropose:
+		if n == 3 {
+			o.phase, o.actor = phaseChoose, opponent(o.actor)
+		}
+	case phaseAddTwo:
+		if n == 5 {
+			o.phase, o.actor = phaseChoose2, opponent(o.actor)
+		}
+	case phaseFourth:
+		o.phase = phaseDeclare
+	case phaseOffer:
+		if o.offered = append(o.offered, [2]int{x, y}); len(o.offered) == o.offers {
+			o.phase, o.actor = phasePick, opponent(g.black)
+		}
+	}
+}
+
+// role 选择执先手(first为true)或者后手
+func (g *Gomoku) chooseSide(role int, first bool) {
+	o := &g.open
+	if (g.black == role) != first {
+		g.swapSides()
+	}
+	o.notice = 0
+	if role == comImgFlag {
+		if first {
+			o.notice = msgComFirst
+		} else {
+			o.notice = msgComSecond
+		}
+	}
+
+	switch {
+	case o.phase == phaseSwap4:
+		o.phase, o.actor = phaseOffer, g.black
+	case o.phase == phaseChoose && g.opening == openSoosorv:
+		o.phase, o.actor = phaseFourth, opponent(g.black)
+	default:
+		g.endOpening()
+	}
+}
+
+// 索索夫后手方声明第5手打点数量,然后由先手方选择是否交换
+func (g *Gomoku) declare(n int) {
+	g.open.offers = n
+	g.open.phase, g.open.actor = phaseSwap4, g.black
+}
+
+// 保留 x,y 的打点,去掉其余打点,开局结束后轮到后手方下第6手
+func (g *Gomoku) pick(x, y int) {
+	for _, p := range g.open.offered {
+		if p != [2]int{x, y} {
+			g.remove(p[0], p[1])
+		}
+	}
+	moves := g.moves[:0]
+	for _, p := range g.moves {
+		if g.board[p[0]][p[1]] != allNoneFlag {
+			moves = append(moves, p) // 落子顺序中也去掉其余打点
+		}
+	}
+	g.moves = moves
+	g.endOpening()
+}
+
+func (g *Gomoku) endOpening() {
+	g.open.phase, g.open.actor, g.open.offered = phaseNone, 0, nil
+	g.open.fixed = len(g.moves)
+}
+
+// a 和 b 两个打点是否对称等价: 存在一种棋盘的旋转或翻转,让其余棋子不变并且把 a 变成 b
+func (g *Gomoku) symmetric(a, b [2]int) bool {
+	base := func(i, j int) int {
+		for _, p := range g.open.offered {
+			if p == [2]int{i, j} {
+				return allNoneFlag // 打点不算局面的一部分
+			}
+		}
+		return g.board[i][j]
+	}
+
+	n := g.size - 1
+	for _, t := range [...]func(i, j int) (int, int){
+		func(i, j int) (int, int) { return n - i, j },
+		func(i, j int) (int, int) { return i, n - j },
+		func(i, j int) (int, int) { return n - i, n - j },
+		func(i, j int) (int, int) { return j, i },
+		func(i, j int) (int, int) { return n - j, i },
+		func(i, j int) (int, int) { return j, n - i },
+		func(i, j int) (int, int) { return n - j, n - i },
+	} {
+		if x, y := t(a[0], a[1]); x != b[0] || y != b[1] {
+			continue
+		}
+		same := true
+		for i := 0; i < g.size && same; i++ {
+			for j := 0; j < g.size && same; j++ {
+				x, y := t(i, j)
+				same = base(i, j) == base(x, y)
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// 开局阶段给玩家的提示
+func (g *Gomoku) openingPrompt() string {
+	o := &g.showOpen
+	switch o.phase {
+	case phasePropose:
+		return fmt.Sprintf(lang[msgOpenPropose], 3-g.showCount())
+	case phaseChoose:
+		if g.opening == openSwap2 {
+			return lang[msgOpenSwap2]
+		}
+		return lang[msgOpenChoose]
+	case phaseChoose2, phaseSwap4:
+		return lang[msgOpenChoose]
+	case phaseAddTwo:
+		return fmt.Sprintf(lang[msgOpenAddTwo], 5-g.showCount())
+	case phaseFourth:
+		return lang[msgOpenFourth]
+	case phaseDeclare:
+		return fmt.Sprintf(lang[msgOpenDeclare], maxOffers)
+	case phaseOffer:
+		return fmt.Sprintf(lang[msgOpenOffer], o.offers-len(o.offered))
+	case phasePick:
+		return lang[msgOpenPick]
+	}
+	return ""
+}
+
+// 界面上的棋子数量
+func (g *Gomoku) showCount() (n int) {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if g.show[i][j] != allNoneFlag {
+				n++
+			}
+		}
+	}
+	return
+}
+
+// 电脑在开局阶段的操作,在 ai 协程中运行,直到轮到玩家或者开局结束
+// 每一步先评估再落子或者选择,评估被取消时结果不完整,不再操作
+func (g *Gomoku) aiOpening() {
+	o := &g.open
+	for o.phase != phaseNone && o.actor == comImgFlag && !g.cancel.Load() && !g.searchStop {
+		switch o.phase {
+		case phasePropose:
+			g.aiBalance(3 - g.count())
+		case phaseAddTwo:
+			g.aiBalance(5 - g.count())
+		case phaseChoose, phaseChoose2:
+			v := g.blackValue()
+			if g.searchStop {
+				return
+			}
+			if o.phase == phaseChoose && g.opening == openSwap2 && math.Abs(v) < openBalanced {
+				o.phase = phaseAddTwo // 局面接近均势,再下两手把选择权交给对方
+			} else {
+				g.chooseSide(comImgFlag, v >= 0)
+			}
+		case phaseFourth:
+			x, y, _ := g.maxMin(openDepth, g.gen(comImgFlag), comImgFlag)
+			if g.searchStop {
+				return
+			}
+			g.place(x, y)
+		case phaseDeclare:
+			// 后手方从多个打点中选择对先手方最不利的一个,打点越多后手方越有利
+			// 先手方还可以交换,所以声明让局面最接近均势的数量
+			_, scores := g.rankFifth()
+			if g.searchStop {
+				return
+			}
+			n := 1
+			for i := 1; i < maxOffers && i < len(scores); i++ {
+				if math.Abs(scores[i]) < math.Abs(scores[n-1]) {
+					n = i + 1
+				}
+			}
+			g.declare(n)
+		case phaseSwap4:
+			points, scores := g.rankFifth()
+			if g.searchStop {
+				return
+			}
+			v := scoreMin
+			if len(points) > 0 {
+				v = scores[min(o.offers, len(scores))-1] // 对方会选择最差的打点
+			}
+			g.chooseSide(comImgFlag, v >= 0)
+		case phaseOffer:
+			points, _ := g.rankFifth()
+			if g.searchStop {
+				return
+			}
+			if len(points) < o.offers {
+				o.offers = len(points) // 不对称的打点不够时,有多少摆多少
+			}
+			for _, p := range points[:o.offers] {
+				g.place(p[0], p[1])
+			}
+		case phasePick:
+			g.aiPick()
+		}
+	}
+}
+
+// 以先手方的角度评估局面,按棋子数量决定轮到哪一方
+func (g *Gomoku) blackValue() float64 {
+	role := g.toMove()
+	v := g.max(openDepth, role, scoreMin, -scoreMin)
+	if role != g.black {
+		v = -v
+	}
+	return v
+}
+
+// 随机尝试多种摆法,选择双方最接近均势的一种下 n 手,新棋子放在已有棋子附近
+func (g *Gomoku) aiBalance(n int) {
+	var (
+		best  [][2]int
+		bestV = math.Inf(1)
+	)
+	for k := 0; k < openSamples; k++ {
+		var ps [][2]int
+		for tries := 0; len(ps) < n && tries < 100; tries++ {
+			x, y := g.size/2, g.size/2
+			if stones := g.stones(); len(stones) > 0 {
+				s := stones[g.rand.Intn(len(stones))]
+				x, y = s[0]+g.rand.Intn(5)-2, s[1]+g.rand.Intn(5)-2
+			}
+			if x < 0 || y < 0 || x >= g.size || y >= g.size || !g.canPlace(x, y) {
+				continue
+			}
+			g.put(x, y, g.stoneRole())
+			ps = append(ps, [2]int{x, y})
+		}
+		v := math.Abs(g.blackValue())
+		for i := len(ps) - 1; i >= 0; i-- {
+			g.remove(ps[i][0], ps[i][1])
+		}
+		if g.searchStop {
+			return
+		}
+		if len(ps) == n && v < bestV {
+			best, bestV = ps, v
+		}
+	}
+	for _, p := range best {
+		g.place(p[0], p[1])
+	}
+}
+
+// 棋盘上所有棋子的位置
+func (g *Gomoku) stones() (ps [][2]int) {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if g.board[i][j] != allNoneFlag {
+				ps = append(ps, [2]int{i, j})
+			}
+		}
+	}
+	return
+}
+
+// 先手方第5手的候选点和落子后的分数,从好到坏排列,对称等价的点只保留一个
+func (g *Gomoku) rankFifth() (points [][2]int, scores []float64) {
+	sp := &skillPoints{}
+	for _, p := range g.gen(g.black) {
+		dup := false
+		for _, q := range sp.points {
+			if dup = g.symmetric([2]int{q[0], q[1]}, [2]int{p[0], p[1]}); dup {
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		g.put(p[0], p[1], g.black)
+		v := g.blackValue()
+		g.remove(p[0], p[1])
+		sp.points = append(sp.points, p)
+		sp.scores = append(sp.scores, v)
+	}
+	sort.Stable(sp)
+	for _, p := range sp.points {
+		points = append(points, [2]int{p[0], p[1]})
+	}
+	return points, sp.scores
+}
+
+// 后手方保留对先手方最不利的打点
+func (g *Gomoku) aiPick() {
+	offered := g.open.offered
+	for _, p := range offered {
+		g.remove(p[0], p[1])
+	}
+	var (
+		best  [2]int
+		bestV = math.Inf(1)
+	)
+	for _, p := range offered {
+		g.put(p[0], p[1], g.black)
+		if v := g.blackValue(); v < bestV {
+			best, bestV = p, v
+		}
+		g.remove(p[0], p[1])
+	}
+	for _, p := range offered {
+		g.put(p[0], p[1], g.black)
+	}
+	if !g.searchStop {
+		g.pick(best[0], best[1])
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 规则
+const (
+	ruleFreestyle = iota // 无禁手,五连或长连都算赢
+	ruleRenju            // 连珠规则,先手黑方禁止三三,四四和长连,只有正好五连才算赢
+	ruleStandard         // 标准五子棋,双方都必须正好五连,长连不算赢但也不禁止
+)
+
+var ruleNames = [...]string{
+	ruleFreestyle: "freestyle",
+	ruleRenju:     "renju",
+	ruleStandard:  "standard",
+}
+
+// 根据名称查找规则
+func findRule(name string) (int, error) {
+	return findName("rule", ruleNames[:], name)
+}
+
+// 在 names 中查找 name 的序号,不区分大小写,kind 用于错误信息
+func findName(kind string, names []string, name string) (int, error) {
+	for i, v := range names {
+		if strings.EqualFold(v, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q, want one of %s", kind, name, strings.Join(names, ", "))
+}
+
+const renjuDepth = 3 // 判断活三时递归检查禁手的最大深度,更深的情况极少出现
+
+// 横,竖,两条斜线四个方向
+var directions = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+
+// x,y 位置的棋子,棋盘外返回-1,和对方棋子一样算作阻挡
+func (g *Gomoku) stone(x, y int) int {
+	if x < 0 || y < 0 || x >= g.size || y >= g.size {
+		return -1
+	}
+	return g.board[x][y]
+}
+
+// role 是否必须正好五连才算赢,长连不算赢
+func (g *Gomoku) exactFive(role int) bool {
+	return g.rule == ruleStandard || g.rule == ruleRenju && role == g.black
+}
+
+// 必须正好五连时修正 mType 的分数,mType 只看棋子和空位数量,会把长连或者只能下成长连的棋形当成五连和四
+// 这里按实际能否正好五连重新计算,只修正冲四以上的棋形,x,y 是假设 role 落子的空位
+func (g *Gomoku) exactType(x, y int, d [2]int, role, score int) int {
+	if score < scoreBlockedFour || !g.exactFive(role) {
+		return score
+	}
+	switch n := g.runLength(x, y, d, role); {
+	case n == 5:
+		return scoreFive
+	case n > 5:
+		return 0 // 长连不算赢,这个方向也不可能再连五
+	}
+	switch len(g.fivePoints(x, y, d, role)) {
+	case 0:
+		return 0 // 再下一子也只能成长连
+	case 1:
+		return scoreBlockedFour
+	default:
+		return scoreFour // 两个成五点对方堵不过来
+	}
+}
+
+// 黑方在 x,y 落子是否禁手,只有连珠规则有禁手,x,y 必须是空位
+func (g *Gomoku) forbidden(x, y int) bool {
+	return g.rule == ruleRenju && g.board[x][y] == allNoneFlag && g.forbiddenAt(x, y, 0)
+}
+
+// 假设黑方在 x,y 落子,判断是否形成长连,四四或者三三,同时形成正好五连时不算禁手
+func (g *Gomoku) forbiddenAt(x, y, depth int) bool {
+	g.board[x][y] = g.black
+	defer func() { g.board[x][y] = allNoneFlag }()
+
+	var (
+		overline bool
+		near     [4]int // 每个方向前后4格内的其他黑子数量
+		lines    int    // 可能形成活三或者冲四的方向数量
+	)
+	for i, d := range directions {
+		switch n := g.runLength(x, y, d, g.black); {
+		case n == 5:
+			return false // 五连直接赢了
+		case n > 5:
+			overline = true
+		}
+		for k := -4; k <= 4; k++ {
+			if k != 0 && g.stone(x+k*d[0], y+k*d[1]) == g.black {
+				near[i]++
+			}
+		}
+		if near[i] >= 2 {
+			lines++
+		}
+	}
+	if overline {
+		return true
+	}
+
+	// 三三和四四至少需要两个方向各有2个黑子,只有同一条线上的四四(例如 1_111_1)例外
+	// 绝大多数空位在这里就能排除,ai 搜索时频繁调用也不会太慢
+	var fours, threes int
+	for i, d := range directions {
+		if near[i] < 2 || lines < 2 && near[i] < 4 {
+			continue
+		}
+		if n := g.fourCount(x, y, d); n > 0 {
+			fours += n
+		} else if g.openThree(x, y, d, g.black, depth) {
+			threes++
+		}
+	}
+	return fours >= 2 || threes >= 2
+}
+
+// x,y 的 role 棋子在方向 d 上连续的同色棋子数量,包括 x,y 本身
+func (g *Gomoku) runLength(x, y int, d [2]int, role int) int {
+	n := 1
+	for _, s := range [...]int{1, -1} {
+		for i, j := x+s*d[0], y+s*d[1]; g.stone(i, j) == role; i, j = i+s*d[0], j+s*d[1] {
+			n++
+		}
+	}
+	return n
+}
+
+// x,y 的 role 棋子在方向 d 上,再下一子就能和它一起连五的空位,返回相对 x,y 的偏移,从小到大
+// 必须正好五连的一方不算长连
+func (g *Gomoku) fivePoints(x, y int, d [2]int, role int) (ks []int) {
+	exact := g.exactFive(role)
+	for k := -4; k <= 4; k++ {
+		i, j := x+k*d[0], y+k*d[1]
+		if k == 0 || g.stone(i, j) != allNoneFlag {
+			continue
+		}
+		g.board[i][j] = role
+		if n := g.runLength(x, y, d, role); n == 5 || n > 5 && !exact {
+			ks = append(ks, k)
+		}
+		g.board[i][j] = allNoneFlag
+	}
+	return
+}
+
+// 方向 d 上四的数量,活四(_1111_)有两个成五点但只算一个四,同一条线上的 1_111_1 算两个四
+func (g *Gomoku) fourCount(x, y int, d [2]int) int {
+	ks := g.fivePoints(x, y, d, g.black)
+	if len(ks) == 2 && ks[1]-ks[0] == 5 {
+		return 1
+	}
+	return len(ks)
+}
+
+// x,y 的 role 棋子在方向 d 上是否活三: 再下一子能成活四,连珠规则的黑方这一子还不能是禁手
+func (g *Gomoku) openThree(x, y int, d [2]int, role, depth int) bool {
+	for k := -3; k <= 3; k++ {
+		i, j := x+k*d[0], y+k*d[1]
+		if k == 0 || g.stone(i, j) != allNoneFlag {
+			continue
+		}
+		g.board[i][j] = role
+		ks := g.fivePoints(x, y, d, role)
+		g.board[i][j] = allNoneFlag
+
+		if len(ks) == 2 && ks[1]-ks[0] == 5 && (g.rule != ruleRenju || role != g.black ||
+			depth >= renjuDepth || !g.forbiddenAt(i, j, depth+1)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 白方在 x,y 落子形成的冲四,成五点都是黑方的禁手时黑方无法防守,相当于活四
+func (g *Gomoku) fourUnstoppable(x, y, role int) bool {
+	if g.rule != ruleRenju || role == g.black {
+		return false
+	}
+	g.board[x][y] = role
+	defer func() { g.board[x][y] = allNoneFlag }()
+
+	for _, d := range directions {
+		ks := g.fivePoints(x, y, d, role)
+		n := 0
+		for _, k := range ks {
+			if g.forbidden(x+k*d[0], y+k*d[1]) {
+				n++
+			}
+		}
+		if n > 0 && n == len(ks) {
+			return true
+		}
+	}
+	return false
+}
+
+// 重新计算界面上标出的禁手点,棋盘变化时在主线程调用,此时 ai 没有在计算
+func (g *Gomoku) markForbidden() {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			g.showForbid[i][j] = g.status == allNoneFlag && g.forbidden(i, j)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const saveInterval = 60 // 每秒检查一次棋盘是否有变化
+
+// 自动保存的对局,关闭窗口时和对局中定时保存,下次启动时可以选择继续
+type savedGame struct {
+	// 每行一个字符串,'.'为空,'o'为玩家一方的棋子,'x'为电脑一方的棋子,Moves 的第一手是黑棋,第i个字符串对应 board[i]
+	Board []string `json:"board"`
+	// 保存时电脑正在思考,恢复后由电脑落子
+	AI bool `json:"ai"`
+	// 落子顺序,用于悔棋和显示手数
+	Moves [][2]int `json:"moves"`
+	// 开局规则摆放的棋子数量,是 Moves 的前几手,不能悔棋
+	Fixed int `json:"fixed,omitempty"`
+	// 对局设置,恢复时替换命令行参数,保证接着下的还是同一局棋
+	Size    int    `json:"size"`
+	Rule    string `json:"rule"`
+	Mode    string `json:"mode"`
+	Opening string `json:"opening"`
+	Elo     int    `json:"elo,omitempty"`
+	// 随机数种子,以及是否指定了种子,这时电脑按节点数限制思考
+	Seed  int64 `json:"seed"`
+	Nodes bool  `json:"nodes,omitempty"`
+}
+
+var saveStones = map[int]byte{allNoneFlag: '.', humImgFlag: 'o', comImgFlag: 'x'}
+
+// 保存对局的文件,在用户配置目录下,浏览器中取不到目录时不保存
+func savePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "LittleGame", "Gomoku.json")
+}
+
+// 读取上次没有下完的对局,没有或者格式不对时返回nil
+// 棋盘大小和规则等可以和这次启动的参数不同,继续时使用保存的设置
+func loadSaved() *savedGame {
+	name := savePath()
+	if name == "" {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("read saved game: %v", err)
+		}
+		return nil
+	}
+
+	s := new(savedGame)
+	if err = json.Unmarshal(data, s); err == nil {
+		err = s.check()
+	}
+	if err != nil {
+		log.Printf("read saved game %s: %v", name, err)
+		return nil
+	}
+	return s
+}
+
+func (s *savedGame) check() error {
+	size := s.Size
+	if size < minBoardSize || size > maxBoardSize {
+		return fmt.Errorf("invalid size %d", size)
+	}
+	if _, err := findRule(s.Rule); err != nil {
+		return err
+	}
+	mode, err := findMode(s.Mode)
+	if err != nil {
+		return err
+	}
+	if open, err := findOpening(s.Opening); err != nil {
+		return err
+	} else if mode != modeComputer && open != openFree {
+		return fmt.Errorf("opening rule %s in the %s mode", s.Opening, s.Mode)
+	}
+	if s.Seed <= 0 || s.Elo < 0 {
+		return fmt.Errorf("invalid seed %d or elo %d", s.Seed, s.Elo)
+	}
+
+	if len(s.Board) != size {
+		return fmt.Errorf("want %d rows, got %d", size, len(s.Board))
+	}
+	stones := 0
+	for i, row := range s.Board {
+		if len(row) != size || strings.Trim(row, ".ox") != "" {
+			return fmt.Errorf("invalid row %d %q", i+1, row)
+		}
+		stones += size - strings.Count(row, ".")
+	}
+	if stones == 0 {
+		return errors.New("no stones on the board") // 空棋盘不会保存,也没有第一手用来确定先手方
+	}
+
+	// 落子顺序要正好包含棋盘上的每个棋子一次
+	seen := make(map[[2]int]bool)
+	for _, m := range s.Moves {
+		if m[0] < 0 || m[1] < 0 || m[0] >= size || m[1] >= size || s.Board[m[0]][m[1]] == '.' || seen[m] {
+			return fmt.Errorf("invalid move %d,%d", m[0], m[1])
+		}
+		seen[m] = true
+	}
+	if len(s.Moves) != stones || s.Fixed < 0 || s.Fixed > stones {
+		return fmt.Errorf("%d moves and %d opening stones do not match %d stones", len(s.Moves), s.Fixed, stones)
+	}
+	return nil
+}
+
+// 当前对局的保存内容,只用主线程的 g.show, g.showMoves 和 g.status,棋盘为空或者已经分出胜负时返回空
+func (g *Gomoku) saveData() string {
+	if g.status != allNoneFlag && g.status != statusComputerRun || g.showOpen.phase != phaseNone {
+		return "" // 开局阶段不保存
+	}
+
+	var (
+		s = savedGame{AI: g.status == statusComputerRun, Moves: g.showMoves, Fixed: g.showOpen.fixed,
+			Size: g.size, Rule: ruleNames[g.rule], Mode: modeNames[g.mode], Opening: openingNames[g.opening],
+			Seed: g.seed, Nodes: g.nodeBudget}
+		empty = true
+		row   [maxBoardSize]byte
+	)
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			row[j] = saveStones[g.show[i][j]]
+			empty = empty && row[j] == '.'
+		}
+		s.Board = append(s.Board, string(row[:g.size]))
+	}
+	if empty {
+		return ""
+	}
+	if g.skill != nil {
+		s.Elo = g.skill.elo
+	}
+	data, _ := json.Marshal(&s)
+	return string(data)
+}
+
+// 棋盘有变化时写入文件,没有需要保存的对局时删除文件
+func (g *Gomoku) autoSave() {
+	if data := g.saveData(); data != g.saved {
+		g.saved = data
+		writeSaved(data)
+	}
+}
+
+// 写入保存的对局,data 为空时删除文件,失败时只记录日志
+func writeSaved(data string) {
+	name := savePath()
+	if name == "" {
+		return
+	}
+	if data == "" {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("remove saved game: %v", err)
+		}
+		return
+	}
+
+	// 先写临时文件再改名,避免写到一半时退出破坏上次的保存
+	err := os.MkdirAll(filepath.Dir(name), 0o755)
+	if err == nil {
+		if err = os.WriteFile(name+".tmp", []byte(data), 0o644); err == nil {
+			err = os.Rename(name+".tmp", name)
+		}
+	}
+	if err != nil {
+		log.Printf("save game: %v", err)
+	}
+}
+
+// 按保存的内容恢复对局,返回true表示轮到电脑落子
+// 先换成保存的设置和种子,界面版本随后要按新的棋盘大小和模式重画背景
+func (g *Gomoku) restore(s *savedGame) bool {
+	g.rule, _ = findRule(s.Rule) // check 中已经检查过名称
+	g.mode, _ = findMode(s.Mode)
+	g.opening, _ = findOpening(s.Opening)
+	g.size, g.nodeBudget = s.Size, s.Nodes
+	g.setSkill(s.Elo)
+	g.setSeed(s.Seed)
+	g.reset()
+	for _, m := range s.Moves {
+		if s.Board[m[0]][m[1]] == 'o' {
+			g.play(m[0], m[1], humImgFlag)
+		} else {
+			g.play(m[0], m[1], comImgFlag)
+		}
+	}
+	g.open.fixed = s.Fixed
+	g.black = g.board[s.Moves[0][0]][s.Moves[0][1]] // 第一手是黑棋
+	return g.comTurn()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 界面每一帧把棋盘复制给 show,保存只用这些副本
+func saveTestData(g *Gomoku) string {
+	g.show, g.showMoves, g.showOpen = g.board, g.moves, g.open
+	return g.saveData()
+}
+
+func TestRestoreSettings(t *testing.T) {
+	// 连珠规则19路双人对弈的对局,用默认参数启动后继续,设置和种子都要换成保存的
+	g := newTestGomoku(t, ruleRenju)
+	g.size, g.mode = 19, modeHuman
+	g.setSkill(skillLevels[1].elo)
+	g.setSeed(42)
+	g.reset()
+	for i, m := range [][2]int{{9, 9}, {9, 10}, {10, 10}} {
+		g.play(m[0], m[1], humImgFlag+i%2)
+	}
+	data := saveTestData(g)
+
+	s := new(savedGame)
+	if err := json.Unmarshal([]byte(data), s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestGomoku(t, ruleFreestyle)
+	r.restore(s)
+	if r.rule != ruleRenju || r.size != 19 || r.mode != modeHuman || r.seed != 42 || r.skill != &skillLevels[1] {
+		t.Fatalf("restored rule %d, size %d, mode %d, seed %d, skill %v", r.rule, r.size, r.mode, r.seed, r.skill)
+	}
+	if r.zobristCode != g.zobristCode || saveTestData(r) != data {
+		t.Fatal("restored game differs from the saved one")
+	}
+}
+
+func TestCheckSaved(t *testing.T) {
+	g := newTestGomoku(t, ruleFreestyle)
+	g.play(7, 7, humImgFlag)
+	valid := saveTestData(g)
+	s := new(savedGame)
+	if err := json.Unmarshal([]byte(valid), s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.check(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有棋子,或者棋子没有落子顺序的保存都不能继续,否则恢复时找不到第一手
+	empty := *s
+	empty.Board = make([]string, s.Size)
+	for i := range empty.Board {
+		empty.Board[i] = strings.Repeat(".", s.Size)
+	}
+	empty.Moves = [][2]int{}
+	noMoves := *s
+	noMoves.Moves = nil
+	for name, bad := range map[string]*savedGame{"empty": &empty, "no moves": &noMoves} {
+		if bad.check() == nil {
+			t.Errorf("%s: saved game accepted", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jan-bar/LittleGame/internal/match"
+)
+
+/*
+相邻棋力等级之间自我对弈,统计高一级的得分率,用来检查 skillLevels 的等级分,不打开游戏窗口,用法:
+
+	GomokuGo -selfplay 20 -seed 1 -time 1s
+
+第n局使用种子 seed+n,按节点数限制思考,相同参数的结果完全一样,没有指定种子时从1开始
+双方交替执黑,黑方第一手下在正中央
+*/
+func (f *gameFlags) runSelfPlay() {
+	if *f.seed == 0 {
+		*f.seed = 1
+	}
+	var (
+		g      = f.newGomoku()
+		levels = make([]int, 0, len(skillLevels)+1)
+	)
+	for _, s := range skillLevels {
+		levels = append(levels, s.elo)
+	}
+	levels = append(levels, 0) // 最后和完整棋力比较
+
+	for i := 1; i < len(levels); i++ {
+		var score match.Score // 高一级的成绩
+		for n := 0; n < *f.selfPlay; n++ {
+			// 偶数局高一级的执黑,奇数局执白
+			black, white := levels[i], levels[i-1]
+			if n%2 == 1 {
+				black, white = white, black
+			}
+			g.setSeed(*f.seed + int64(n))
+			r, _ := g.selfPlayGame(black, white)
+			if n%2 == 1 {
+				r = -r
+			}
+			score.Add(r)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%s vs %s: %v\n", eloName(levels[i]), eloName(levels[i-1]), score)
+	}
+}
+
+// 下一局棋,返回黑方的结果和全部落子,赢了为1,和棋为0,输了为-1,等级分为0时使用完整棋力
+// 黑方用 comImgFlag,白方用 humImgFlag,每一步都清空置换表,双方不共用搜索结果
+func (g *Gomoku) selfPlayGame(black, white int) (int, [][2]int) {
+	g.reset()
+	g.nodeBudget = true
+	g.black = comImgFlag
+	g.play(g.size/2, g.size/2, comImgFlag)
+
+	status := allNoneFlag
+	for status == allNoneFlag {
+		role := g.toMove()
+		if role == comImgFlag {
+			g.setSkill(black)
+		} else {
+			g.setSkill(white)
+		}
+		g.tt.clear()
+		x, y := g.aiMove(role)
+		g.play(x, y, role)
+		status = g.result(x, y, role)
+	}
+
+	switch status {
+	case statusBlackWin:
+		return 1, g.moves
+	case statusWhiteWin:
+		return -1, g.moves
+	}
+	return 0, g.moves
+}
+
+func eloName(elo int) string {
+	if s := findSkill(elo); s != nil {
+		return s.String()
+	}
+	return "full"
+}
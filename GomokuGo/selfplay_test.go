@@ -0,0 +1,20 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelfPlayReproducible(t *testing.T) {
+	// 相同种子的自我对弈落子一样,-selfplay 统计的得分率可以复现
+	play := func() (int, [][2]int) {
+		g := newTestGomoku(t, ruleFreestyle)
+		r, moves := g.selfPlayGame(skillLevels[0].elo, skillLevels[1].elo)
+		return r, slices.Clone(moves)
+	}
+	r1, m1 := play()
+	r2, m2 := play()
+	if r1 != r2 || len(m1) < 9 || !slices.Equal(m1, m2) {
+		t.Fatalf("games differ: result %d %v, result %d %v", r1, m1, r2, m2)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 棋力等级,限制搜索深度和候选点数量,并按一定概率选择分数差距不大的次优落子
+// 让初学者和小朋友偶尔也能赢,但不会放着对方活四冲四不管
+type skillLevel struct {
+	elo        int     // 等级分,由相邻等级自我对弈的得分率推算,不是和人对局测量的
+	searchDeep int     // 最大搜索深度
+	countLimit int     // gen函数返回的节点数量上限
+	margin     float64 // 可选落子与最佳落子的最大分差
+	chance     int     // 选择次优落子的概率,百分比
+}
+
+// 按等级分从低到高排列,不指定等级分时使用完整棋力
+// 最低一级定为1100,其他等级加上和下一级的分差,GomokuGo -selfplay 40 -seed 1 的结果(无禁手,默认思考时间):
+//
+//	1130 对 1100      +20 =3 -17 得分率54% 分差+26
+//	1180 对 1130      +22 =2 -16 得分率57% 分差+53
+//	完整棋力 对 1180  +27 =0 -13 得分率68% 分差+127
+//
+// 随机选择次优落子几乎不影响棋力,强弱主要取决于搜索深度和完整棋力的算杀,所以等级范围较窄
+// 40局的得分率误差约8%,相邻等级的分差只能作为大致参考
+var skillLevels = []skillLevel{
+	{elo: 1100, searchDeep: 1, countLimit: 3, margin: 5000, chance: 100},
+	{elo: 1130, searchDeep: 3, countLimit: 5},
+	{elo: 1180, searchDeep: 5, countLimit: 5},
+}
+
+// 根据等级分选择不高于它的最高等级,低于最低等级时使用最低等级,elo<=0返回nil表示完整棋力
+func findSkill(elo int) *skillLevel {
+	if elo <= 0 {
+		return nil
+	}
+	s := &skillLevels[0]
+	for i := range skillLevels {
+		if skillLevels[i].elo <= elo {
+			s = &skillLevels[i]
+		}
+	}
+	return s
+}
+
+// 按等级分设置棋力,elo 为0时使用完整棋力,gen 返回的节点数量也恢复默认值
+func (g *Gomoku) setSkill(elo int) {
+	g.skill, g.countLimit = findSkill(elo), 10
+	if g.skill != nil {
+		g.countLimit = g.skill.countLimit
+	}
+}
+
+func (s *skillLevel) String() string {
+	return fmt.Sprintf("elo:%d", s.elo)
+}
+
+type skillPoints struct {
+	points [][]int
+	scores []float64
+}
+
+func (s *skillPoints) Len() int {
+	return len(s.points)
+}
+func (s *skillPoints) Less(i, j int) bool {
+	return s.scores[i] > s.scores[j] // 分数从高到低
+}
+func (s *skillPoints) Swap(i, j int) {
+	s.points[i], s.points[j] = s.points[j], s.points[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
+
+// 按棋力等级选择 ai 为 role 一方的落子
+// 与 maxMin 不同,每个候选点都用完整窗口搜索,得到准确分数后再随机选择分差不超过 margin 的落子
+func (g *Gomoku) skillPoint(role int) (x, y int) {
+	sp := &skillPoints{points: g.gen(role)}
+	if len(sp.points) == 0 {
+		x, y, _ = g.anyPoint(role) // 附近的位置都是禁手点
+		return
+	}
+	for _, p := range sp.points {
+		g.put(p[0], p[1], role)
+		v := -g.max(g.skill.searchDeep-1, opponent(role), scoreMin, -scoreMin)
+		g.remove(p[0], p[1])
+		sp.scores = append(sp.scores, g.edgeScore(p, v))
+	}
+	sort.Stable(sp)
+
+	n := 1
+	for n < len(sp.points) && g.equal(sp.scores[n], sp.scores[0]) {
+		n++ // 与 maxMin 一样,分数相同的落子随机选择
+	}
+	if sp.scores[0] < scoreFour && g.rand.Intn(100) < g.skill.chance {
+		// 没有必胜落子时才考虑次优落子,且不选择让对方形成活四以上的落子
+		for n < len(sp.points) && sp.scores[n] >= sp.scores[0]-g.skill.margin &&
+			sp.scores[n] > -scoreFour/2 {
+			n++
+		}
+	}
+	p := sp.points[g.rand.Intn(n)]
+	return p[0], p[1]
+}
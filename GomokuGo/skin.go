@@ -0,0 +1,86 @@
+//go:build !nogui
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+)
+
+const (
+	stoneSize      = 32  // 棋子图片大小,画在棋盘线交叉点的中心
+	backgroundSize = 630 // 背景图片大小,正好是15路棋盘的窗口宽度,其他大小的棋盘缩放显示
+)
+
+// 打开皮肤包,可以是目录或者zip文件,返回的 io.Closer 在读取完图片后关闭
+func openSkin(name string) (fs.FS, io.Closer, error) {
+	st, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("skin: %w", err)
+	}
+	if st.IsDir() {
+		return os.DirFS(name), io.NopCloser(nil), nil
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("skin %s: not a directory or zip file: %w", name, err)
+	}
+	return zr, zr, nil
+}
+
+// 解码界面图片,顺序和 Gomoku.img 相同,皮肤包中有同名文件时替换内置图片
+// 皮肤包的图片必须和内置图片一样大,否则棋子对不齐棋盘线
+func loadImages(skinName string) (imgs [5]image.Image, err error) {
+	var skin fs.FS
+	if skinName != "" {
+		var sc io.Closer
+		if skin, sc, err = openSkin(skinName); err != nil {
+			return
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer sc.Close()
+	}
+
+	for i, v := range [...]struct {
+		name string
+		data []byte
+		size int
+	}{
+		{"White.png", humImgData, stoneSize},
+		{"Black.png", comImgData, stoneSize},
+		{"WhiteWin.png", humImgWinData, stoneSize},
+		{"BlackWin.png", comImgWinData, stoneSize},
+		{"background.jpg", background, backgroundSize},
+	} {
+		data, fromSkin := v.data, false
+		if skin != nil {
+			b, err := fs.ReadFile(skin, v.name)
+			if err == nil {
+				data, fromSkin = b, true
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return imgs, fmt.Errorf("skin %s: %w", skinName, err)
+			}
+		}
+
+		imgs[i], _, err = image.Decode(bytes.NewReader(data))
+		if err == nil && fromSkin {
+			if b := imgs[i].Bounds(); b.Dx() != v.size || b.Dy() != v.size {
+				err = fmt.Errorf("image is %dx%d, want %dx%d", b.Dx(), b.Dy(), v.size, v.size)
+			}
+		}
+		if err != nil {
+			if fromSkin {
+				err = fmt.Errorf("skin %s: %s: %w", skinName, v.name, err)
+			}
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package main
+
+// 置换表,固定大小,用 zobrist code 的低位作为索引,完整的 code 用于确认是同一个局面
+const (
+	ttBits = 18 // 262144 项,约8MB
+	ttSize = 1 << ttBits
+	ttMask = ttSize - 1
+)
+
+// 置换表中分数的类型
+const (
+	ttExact = iota + 1 // 准确分数
+	ttLower            // 发生了 beta 剪枝,真实分数不低于 score
+	ttUpper            // 所有落子都没有超过 alpha,真实分数不高于 score
+)
+
+var noMove = [2]int8{-1, -1}
+
+type (
+	ttEntry struct {
+		key   int64   // 完整的 zobrist code
+		score float64 // 搜索分数,含义由 flag 决定
+		eval  float64 // 静态估分,和搜索深度无关
+		depth int8    // 搜索深度
+		flag  int8    // 分数类型
+		role  int8    // 轮到哪一方落子
+		age   uint8   // 写入时的代数,和当前代数不同时视为空
+		move  [2]int8 // 最佳落子,没有时为 noMove
+	}
+	transTable struct {
+		entries []ttEntry
+		age     uint8
+	}
+)
+
+func newTransTable() *transTable {
+	return &transTable{entries: make([]ttEntry, ttSize), age: 1}
+}
+
+// 新的一局或者双方交换棋子后,旧的分数不再可信,增加代数让它们全部失效
+// 代数用完一轮时才真正清空
+func (t *transTable) clear() {
+	if t.age++; t.age == 0 {
+		clear(t.entries)
+		t.age = 1
+	}
+}
+
+// 查找 key 局面轮到 role 落子时的记录,没有时返回nil
+func (t *transTable) probe(key int64, role int) *ttEntry {
+	if e := &t.entries[key&ttMask]; e.age == t.age && e.key == key && e.role == int8(role) {
+		return e
+	}
+	return nil
+}
+
+// 写入记录,同一代中已有搜索更深的记录时保留原来的
+// 同一个局面也一样,例如 max 到达深度时写入的0层分数不能覆盖更深的分数和最佳落子
+func (t *transTable) store(key int64, role, depth, flag int, score, eval float64, move [2]int8) {
+	e := &t.entries[key&ttMask]
+	if e.age == t.age && int(e.depth) > depth {
+		return
+	}
+	*e = ttEntry{key: key, score: score, eval: eval, depth: int8(depth), flag: int8(flag),
+		role: int8(role), age: t.age, move: move}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestTransTableKeepsDeeper(t *testing.T) {
+	tt := newTransTable()
+	const key = 12345
+	tt.store(key, comImgFlag, 4, ttLower, 500, 100, [2]int8{7, 8})
+	tt.store(key, comImgFlag, 0, ttExact, 100, 100, noMove)         // 到达深度时的叶子节点
+	tt.store(key, comImgFlag, 2, ttUpper, -200, 100, [2]int8{1, 2}) // 较浅的搜索得到的上界
+
+	e := tt.probe(key, comImgFlag)
+	if e == nil || e.depth != 4 || e.flag != ttLower || e.score != 500 || e.move != [2]int8{7, 8} {
+		t.Fatalf("deeper entry replaced: %+v", e)
+	}
+
+	tt.store(key, comImgFlag, 6, ttExact, 300, 100, [2]int8{3, 4})
+	if e = tt.probe(key, comImgFlag); e.depth != 6 || e.move != [2]int8{3, 4} {
+		t.Fatalf("deeper search not stored: %+v", e)
+	}
+
+	tt.clear()
+	tt.store(key, comImgFlag, 0, ttExact, 100, 100, noMove) // 旧的一代不再保留
+	if e = tt.probe(key, comImgFlag); e == nil || e.depth != 0 {
+		t.Fatalf("entry of the old age kept: %+v", e)
+	}
+}
@@ -0,0 +1,410 @@
+//go:build !nogui
+
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/jan-bar/LittleGame/internal/ui"
+)
+
+// 窗口界面: 图片,输入和绘制,用 -tags nogui 编译时不包含这些,不依赖 ebiten
+
+func main() {
+	gf := newGameFlags()
+	fl := flag.String("lang", "", "ui language, en or zh, default from system locale")
+	fm := flag.String("mode", modeNames[modeComputer], "game mode, computer, human or auto,\n"+
+		"computer plays against the computer, B to play black who moves first and W to play white,\n"+
+		"human lets two players take turns on this computer, auto lets the computer play both colors")
+	fo := flag.String("opening", openingNames[openFree], "opening rule, free, swap, swap2 or soosorv, only in the computer mode,\n"+
+		"with an opening rule B lets the player and W lets the computer place the opening stones, then the other side chooses")
+	fz := flag.Int("size", 15, fmt.Sprintf("board size, %d to %d lines", minBoardSize, maxBoardSize))
+	fn := flag.Bool("numbers", false, "show move numbers on the stones, also toggled with the M key")
+	fk := flag.String("skin", "", "skin directory or zip file with White.png, Black.png, WhiteWin.png, BlackWin.png\n"+
+		"or background.jpg replacing the built-in images, missing files fall back to the built-in ones")
+	flag.Parse()
+	if *gf.brain {
+		gf.runBrain() // 作为引擎运行,不打开窗口
+		return
+	}
+	if *gf.selfPlay > 0 {
+		gf.runSelfPlay()
+		return
+	}
+	setLang(*fl)
+	opening, err := findOpening(*fo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mode, err := findMode(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if mode != modeComputer && opening != openFree {
+		log.Fatalf("opening rule %s needs the computer mode", *fo)
+	}
+	if *fz < minBoardSize || *fz > maxBoardSize {
+		log.Fatalf("invalid size %d, must be %d to %d", *fz, minBoardSize, maxBoardSize)
+	}
+
+	g := gf.newGomoku()
+	g.opening, g.mode, g.size, g.numbers = opening, mode, *fz, *fn
+	g.start(humImgFlag)
+	g.resume = loadSaved() // 上次没有下完的对局,询问是否继续
+	imgs, err := loadImages(*fk)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, img := range imgs {
+		g.img[i] = ebiten.NewImageFromImage(img)
+	}
+	g.drawBoard()
+
+	go g.ai() // 启动协程运行ai
+
+	ebiten.SetWindowSize(g.screenWidth(), g.screenHeight())
+	ebiten.SetWindowClosingHandled(true) // 关闭窗口前保存对局
+	ebiten.SetWindowTitle(lang[msgTitle])
+	if err := ebiten.RunGame(g); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var (
+	//go:embed White.png
+	humImgData []byte
+	//go:embed WhiteWin.png
+	humImgWinData []byte
+	//go:embed Black.png
+	comImgData []byte
+	//go:embed BlackWin.png
+	comImgWinData []byte
+	//go:embed background.jpg
+	background []byte
+
+	forbidColor = color.RGBA{R: 0xe0, A: 0xff}
+)
+
+// 只有界面版本使用的数据
+type view struct {
+	// 缓存图片对象
+	img [5]*ebiten.Image
+	// 画好棋盘线的背景
+	bgImg *ebiten.Image
+}
+
+// 背景图片按棋盘大小缩放,画上棋盘线和坐标,下面多出的部分显示操作提示
+// 启动时和恢复的对局改变了棋盘大小或模式时调用
+func (g *Gomoku) drawBoard() {
+	w := g.screenWidth()
+	bg := ebiten.NewImage(w, g.screenHeight())
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(w)/backgroundSize, float64(w)/backgroundSize)
+	bg.DrawImage(g.img[4], op)
+
+	lineColor := color.RGBA{R: 0, G: 0, B: 0, A: 0xff}
+	for i := 0; i < g.size; i++ {
+		var (
+			lt  = strconv.Itoa(i + 1)
+			ln  = 25 + 40*i // 通过调试得到计算数值
+			lnf = float32(ln)
+		)
+		// 为背景图片添加横竖线条,以及每个线条对应数字
+		vector.StrokeLine(bg, 0, lnf, float32(w), lnf, 1, lineColor, false)
+		ui.DrawText(bg, lt, w-30, ln)
+		vector.StrokeLine(bg, lnf, 0, lnf, float32(w), 1, lineColor, false)
+		ui.DrawText(bg, lt, ln, w-20)
+	}
+	ui.DrawText(bg, lang[modeHelp[g.mode]], 10, w+20)
+	g.bgImg = bg
+}
+
+func (g *Gomoku) Update() error {
+	if err := g.updateSave(); err != nil {
+		return err // 关闭窗口,已经保存对局
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.numbers = !g.numbers // 只影响显示,电脑思考时也可以切换
+	}
+
+	var sendAI bool
+	// 这里的chan确保 g.status 是线程安全
+	select {
+	case s, ok := <-g.aiStatus:
+		if ok {
+			g.status = s
+			if s == allNoneFlag {
+				sendAI = g.comTurn() // 电脑对弈电脑时继续由另一方落子
+			}
+		}
+	default:
+		if g.status == statusComputerRun {
+			if !inpututil.IsKeyJustPressed(ebiten.KeyB) && !inpututil.IsKeyJustPressed(ebiten.KeyW) {
+				return nil // ai 思考时只能重新开始
+			}
+			g.stopAI()
+		}
+	}
+
+	if g.resume != nil {
+		sendAI = g.updateResume() // 询问是否继续上次的对局
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		sendAI = g.start(humImgFlag) // 按下B键重新开始游戏,玩家执黑先手,有开局规则时由玩家摆放开局
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		sendAI = g.start(comImgFlag) // 按下W键重新开始游戏,玩家执白后手
+	} else if g.open.phase != phaseNone {
+		if g.status == allNoneFlag {
+			sendAI = g.updateOpening() // 按开局规则摆放开局或者选择先后手
+		}
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyU) {
+		g.undo() // 悔棋,分出胜负后也可以
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		g.redoMove()
+	} else if role := g.toMove(); g.status == allNoneFlag && !g.aiPlays(role) &&
+		inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := ebiten.CursorPosition()
+		x, y = (x-9)/40, (y-9)/40 // 计算鼠标点击位置,此位置没有落子时才响应
+		if x >= 0 && y >= 0 && x < g.size && y < g.size && g.board[x][y] == allNoneFlag &&
+			(g.black != role || !g.forbidden(x, y)) { // 黑方不能下在禁手点
+			g.play(x, y, role)
+			g.open.notice = 0
+			if g.status = g.result(x, y, role); g.status == allNoneFlag {
+				sendAI = g.comTurn() // 轮到电脑时设置为思考中
+			}
+		}
+	}
+
+	changed := g.show != g.board
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			g.show[i][j] = g.board[i][j]
+		}
+	}
+	g.showOpen, g.showBlack = g.open, g.black
+	g.showOpen.offered = append([][2]int(nil), g.open.offered...)
+	g.showMoves = append(g.showMoves[:0], g.moves...)
+	if changed {
+		g.markForbidden()
+	}
+
+	// 在将 g.board 复制到 g.show 之后才该ai发消息,确保线程安全
+	// 更新UI只用到了 g.status 和 g.show ,确保这两个变量线程安全就OK
+	// 这时 ai 协程空闲,或者刚发完结果马上回到等待,电脑对弈电脑时同一帧就要发,不能丢弃
+	if sendAI {
+		g.aiStatus <- 0 // 发信号让ai
+	}
+	return nil
+}
+
+// 让正在思考的 ai 放弃计算,等它退出后主线程才能修改棋盘
+// ai 可能刚好已经落子,这时收到的状态也丢弃,调用方会重新开始或者结束游戏
+func (g *Gomoku) stopAI() {
+	g.cancel.Store(true)
+	<-g.aiStatus
+	g.cancel.Store(false)
+	g.status = allNoneFlag
+}
+
+func (g *Gomoku) Draw(screen *ebiten.Image) {
+	screen.DrawImage(g.bgImg, nil)
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if v := g.show[i][j]; v != allNoneFlag {
+				op := &ebiten.DrawImageOptions{}
+				op.GeoM.Translate(float64(9+40*i), float64(9+40*j))
+				screen.DrawImage(g.stoneImage(v), op)
+			} else if g.showForbid[i][j] {
+				// 禁手点画红色叉
+				x, y := float32(25+40*i), float32(25+40*j)
+				vector.StrokeLine(screen, x-6, y-6, x+6, y+6, 2, forbidColor, true)
+				vector.StrokeLine(screen, x-6, y+6, x+6, y-6, 2, forbidColor, true)
+			}
+		}
+	}
+	if g.numbers {
+		g.drawNumbers(screen)
+	}
+	for _, p := range g.showOpen.offered {
+		// 索索夫开局的第5手打点画圆圈
+		vector.StrokeCircle(screen, float32(25+40*p[0]), float32(25+40*p[1]), 18, 2, forbidColor, true)
+	}
+
+	switch {
+	case g.resume != nil:
+		ui.DrawText(screen, lang[msgResume], 10, g.screenWidth())
+	case g.status == statusComputerRun:
+		ui.DrawText(screen, lang[msgAIThink], 10, g.screenWidth())
+	case g.status == statusWhiteWin:
+		ui.DrawText(screen, lang[msgWhiteWin], 10, g.screenWidth())
+	case g.status == statusBlackWin:
+		ui.DrawText(screen, lang[msgBlackWin], 10, g.screenWidth())
+	case g.status == statusDraw:
+		ui.DrawText(screen, lang[msgDraw], 10, g.screenWidth())
+	case g.showOpen.phase != phaseNone:
+		ui.DrawText(screen, g.openingPrompt(), 10, g.screenWidth())
+	case g.showOpen.notice != 0:
+		ui.DrawText(screen, lang[g.showOpen.notice], 10, g.screenWidth())
+	case g.mode == modeHuman && g.status == allNoneFlag:
+		// 两个玩家对弈时提示轮到哪一方
+		if len(g.showMoves)%2 == 0 {
+			ui.DrawText(screen, lang[msgBlackTurn], 10, g.screenWidth())
+		} else {
+			ui.DrawText(screen, lang[msgWhiteTurn], 10, g.screenWidth())
+		}
+	}
+
+	seed := fmt.Sprintf("seed:%d", g.seed)
+	if g.skill != nil {
+		seed = g.skill.String() + " " + seed
+	}
+	if g.rule != ruleFreestyle {
+		seed = ruleNames[g.rule] + " " + seed
+	}
+	if g.opening != openFree {
+		seed = openingNames[g.opening] + " " + seed
+	}
+	if g.mode != modeComputer {
+		seed = modeNames[g.mode] + " " + seed
+	}
+	w := g.screenWidth()
+	ui.DrawText(screen, seed, w-5-int(text.Advance(seed, ui.Face)), w)
+}
+
+// 棋盘上 v 的图片,先手方用黑棋,另一方用白棋
+func (g *Gomoku) stoneImage(v int) *ebiten.Image {
+	i := 0 // 图片顺序为白棋,黑棋,白棋赢了,黑棋赢了
+	if v == humWinImgFlag || v == comWinImgFlag {
+		i, v = 2, v-humWinImgFlag+humImgFlag
+	}
+	if v == g.showBlack {
+		i++
+	}
+	return g.img[i]
+}
+
+func (g *Gomoku) Layout(_, _ int) (int, int) {
+	return g.screenWidth(), g.screenHeight()
+}
+
+// 窗口宽度,棋盘右边和下边留出显示坐标的位置,15路时正好是背景图片的大小
+func (g *Gomoku) screenWidth() int {
+	return 40*g.size + 30
+}
+
+// 窗口比棋盘高一些,第一行显示状态和种子,第二行显示操作提示
+func (g *Gomoku) screenHeight() int {
+	return g.screenWidth() + 40
+}
+
+// 在棋子中央画手数
+func (g *Gomoku) drawNumbers(screen *ebiten.Image) {
+	for i, p := range g.showMoves {
+		s := strconv.Itoa(i + 1)
+		ui.DrawText(screen, s, 25+40*p[0]-int(text.Advance(s, ui.Face)/2), 25+40*p[1]-7)
+	}
+}
+
+// 玩家在开局阶段的操作,落子用鼠标,选择和声明用数字键
+// 返回true表示轮到电脑操作
+func (g *Gomoku) updateOpening() (sendAI bool) {
+	o := &g.open
+	if o.actor != humImgFlag {
+		return false
+	}
+
+	digits := [...]ebiten.Key{ebiten.KeyDigit1, ebiten.KeyDigit2, ebiten.KeyDigit3, ebiten.KeyDigit4,
+		ebiten.KeyDigit5, ebiten.KeyDigit6, ebiten.KeyDigit7, ebiten.KeyDigit8}
+	switch o.phase {
+	case phaseChoose, phaseChoose2, phaseSwap4:
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyDigit1):
+			g.chooseSide(humImgFlag, true)
+		case inpututil.IsKeyJustPressed(ebiten.KeyDigit2):
+			g.chooseSide(humImgFlag, false)
+		case o.phase == phaseChoose && g.opening == openSwap2 && inpututil.IsKeyJustPressed(ebiten.KeyDigit3):
+			o.phase, o.notice = phaseAddTwo, 0
+		default:
+			return false
+		}
+	case phaseDeclare:
+		n := 0
+		for i, k := range digits[:maxOffers] {
+			if inpututil.IsKeyJustPressed(k) {
+				n = i + 1
+			}
+		}
+		if n == 0 {
+			return false
+		}
+		g.declare(n)
+	default:
+		if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			return false
+		}
+		x, y := ebiten.CursorPosition()
+		x, y = (x-9)/40, (y-9)/40
+		if x < 0 || y < 0 || x >= g.size || y >= g.size {
+			return false
+		}
+		if o.phase == phasePick {
+			for _, p := range o.offered {
+				if p == [2]int{x, y} {
+					g.pick(x, y)
+					return g.comTurn()
+				}
+			}
+			return false
+		}
+		if !g.canPlace(x, y) {
+			return false
+		}
+		g.place(x, y)
+	}
+	return g.comTurn()
+}
+
+// 定时保存对局,关闭窗口时保存后返回 ebiten.Termination 结束游戏
+func (g *Gomoku) updateSave() error {
+	if ebiten.IsWindowBeingClosed() {
+		if g.resume == nil {
+			g.autoSave() // 还没选择是否继续时,保留上次的对局
+		}
+		if g.status == statusComputerRun {
+			g.stopAI() // 保存的是电脑落子前的棋盘,恢复后重新思考
+		}
+		return ebiten.Termination
+	}
+	if g.saveTick++; g.resume == nil && g.saveTick >= saveInterval {
+		g.saveTick = 0
+		g.autoSave()
+	}
+	return nil
+}
+
+// 启动时询问是否继续上次的对局,Y键继续,N键新开一局
+// 返回true表示恢复的对局轮到电脑落子
+func (g *Gomoku) updateResume() (sendAI bool) {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyY):
+		sendAI = g.restore(g.resume)
+		g.resume = nil
+		g.drawBoard()
+		ebiten.SetWindowSize(g.screenWidth(), g.screenHeight())
+		log.Printf("resumed a saved game, seed: %d", g.seed)
+	case inpututil.IsKeyJustPressed(ebiten.KeyN):
+		g.resume = nil
+		writeSaved("") // 不再继续,删除保存的对局
+	}
+	return
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// 算杀: 连续冲四取胜(VCF)和连续冲四活三取胜(VCT)
+// 只看冲四和活三这类对方必须应对的落子,分支比 maxMin 少得多,可以算得很深
+const (
+	vcfDepth  = 12                     // VCF 进攻方最多落子数量
+	vctDepth  = 6                      // VCT 进攻方最多落子数量
+	killWidth = 12                     // 每一步最多尝试的进攻落子
+	killTime  = 800 * time.Millisecond // 电脑每一步找必胜和检查对方必胜各自的时间上限,不超过思考时间的四分之一
+
+	killNodesPerSec = 1000 // 按节点数限制思考时,算杀每秒大致能搜索的节点数,每个节点都要扫描整个棋盘,比 maxMin 慢得多
+)
+
+// role 在空位 x,y 落子能否连五,必须正好五连的一方不算长连
+func (g *Gomoku) fiveAt(x, y, role int) bool {
+	exact := g.exactFive(role)
+	for _, d := range directions {
+		if n := g.runLength(x, y, d, role); n == 5 || n > 5 && !exact {
+			return true
+		}
+	}
+	return false
+}
+
+// role 落子就能连五的空位
+func (g *Gomoku) fiveMoves(role int) (ps [][2]int) {
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if g.board[i][j] == allNoneFlag && g.fiveAt(i, j, role) {
+				ps = append(ps, [2]int{i, j})
+			}
+		}
+	}
+	return
+}
+
+// role 落子后能形成冲四的空位,threes 为true时还包括形成活三的空位
+// 冲四排在前面,同类的按分数从高到低
+func (g *Gomoku) threatMoves(role int, threes bool) [][2]int {
+	score := &g.humScore
+	if role == comImgFlag {
+		score = &g.comScore
+	}
+
+	var fours, opens [][2]int
+	for i := 0; i < g.size; i++ {
+		for j := 0; j < g.size; j++ {
+			if !g.hasNeighbor(i, j) || role == g.black && g.forbidden(i, j) {
+				continue
+			}
+			g.board[i][j] = role
+			four, three := false, false
+			for _, d := range directions {
+				// 这条线前后4格内至少要有3个同色棋子才可能成四,2个才可能成活三,大部分空位在这里就排除了
+				n := 0
+				for k := -4; k <= 4; k++ {
+					if k != 0 && g.stone(i+k*d[0], j+k*d[1]) == role {
+						n++
+					}
+				}
+				if n >= 3 && len(g.fivePoints(i, j, d, role)) > 0 {
+					four = true
+					break
+				}
+				three = three || threes && n >= 2 && g.openThree(i, j, d, role, 0)
+			}
+			g.board[i][j] = allNoneFlag
+
+			if four {
+				fours = append(fours, [2]int{i, j})
+			} else if three {
+				opens = append(opens, [2]int{i, j})
+			}
+		}
+	}
+
+	for _, ps := range [][][2]int{fours, opens} {
+		sort.SliceStable(ps, func(a, b int) bool {
+			return score[ps[a][0]][ps[a][1]] > score[ps[b][0]][ps[b][1]]
+		})
+	}
+	return append(fours, opens...)
+}
+
+// 每隔一段节点检查一次是否超时或者被取消,之后所有算杀都返回没有找到
+func (g *Gomoku) killTimeout() bool {
+	if g.killNodes++; g.cancel.Load() || g.killNodes%16 == 0 && overBudget(g.killNodes, g.killLimit, g.killDeadline) {
+		g.killStop = true
+	}
+	return g.killStop
+}
+
+// 算杀从现在开始最多用 d 时间,按节点数限制时换算成节点数
+func (g *Gomoku) killBudget(d time.Duration) {
+	if g.nodeBudget {
+		g.killDeadline, g.killLimit = time.Time{}, g.killNodes+budgetNodes(d, killNodesPerSec)
+	} else {
+		g.killDeadline, g.killLimit = time.Now().Add(d), 0
+	}
+}
+
+// 轮到 role 落子时能否在 depth 步之内算杀成功,fourOnly 为true时只用冲四(VCF),否则还可以用活三(VCT)
+// 成功时返回第一步落子
+func (g *Gomoku) kill(role, depth int, fourOnly bool) (p [2]int, ok bool) {
+	if fives := g.fiveMoves(role); len(fives) > 0 {
+		return fives[0], true // 直接连五
+	}
+	if depth <= 0 || g.killTimeout() {
+		return
+	}
+
+	moves := g.threatMoves(role, !fourOnly)
+	switch fives := g.fiveMoves(opponent(role)); len(fives) {
+	case 0:
+	case 1:
+		// 对方有冲四,只能先挡住,挡的这一手同时是进攻才能继续
+		var block [][2]int
+		for _, m := range moves {
+			if m == fives[0] {
+				block = append(block, m)
+			}
+		}
+		moves = block
+	default:
+		return // 对方有两个连五点,挡不住
+	}
+	if len(moves) > killWidth {
+		moves = moves[:killWidth]
+	}
+
+	for _, m := range moves {
+		g.put(m[0], m[1], role)
+		ok = g.killReply(role, depth, fourOnly, m)
+		g.remove(m[0], m[1])
+		if ok {
+			return m, true
+		}
+	}
+	return
+}
+
+// role 在 m 落子形成冲四或活三之后,对方的每一种防守都还能继续算杀成功时返回true
+func (g *Gomoku) killReply(role, depth int, fourOnly bool, m [2]int) bool {
+	opp := opponent(role)
+	var defences [][2]int
+	switch fives := g.fiveMoves(role); {
+	case len(fives) >= 2:
+		return true // 活四或者双四,对方挡不住
+	case len(fives) == 1:
+		defences = fives // 冲四只能挡在连五点
+	case fourOnly:
+		return false
+	default:
+		// 活三可以挡在这条线上让进攻方成四的点,或者先冲四反击
+		seen := make(map[[2]int]bool)
+		for _, d := range directions {
+			if !g.openThree(m[0], m[1], d, role, 0) {
+				continue
+			}
+			for k := -4; k <= 4; k++ {
+				i, j := m[0]+k*d[0], m[1]+k*d[1]
+				if g.stone(i, j) != allNoneFlag || seen[[2]int{i, j}] {
+					continue
+				}
+				g.board[i][j] = role
+				four := len(g.fivePoints(m[0], m[1], d, role)) > 0
+				g.board[i][j] = allNoneFlag
+				if four {
+					seen[[2]int{i, j}] = true
+					defences = append(defences, [2]int{i, j})
+				}
+			}
+		}
+		for _, p := range g.threatMoves(opp, false) {
+			if !seen[p] {
+				defences = append(defences, p)
+			}
+		}
+	}
+
+	for _, p := range defences {
+		if opp == g.black && g.forbidden(p[0], p[1]) {
+			continue // 连珠规则黑方不能下在禁手点防守
+		}
+		if g.fiveAt(p[0], p[1], opp) {
+			return false // 防守的一手自己连五了
+		}
+		g.put(p[0], p[1], opp)
+		_, ok := g.kill(role, depth-1, fourOnly)
+		g.remove(p[0], p[1])
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 在 limit 时间内依次尝试 VCF 和 VCT
+func (g *Gomoku) killSearch(role int, limit time.Duration) (p [2]int, ok bool) {
+	g.killNodes, g.killStop = 0, false
+	g.killBudget(limit)
+	if p, ok = g.kill(role, vcfDepth, true); !ok && !g.killStop {
+		p, ok = g.kill(role, vctDepth, false)
+	}
+	return
+}
+
+// 电脑为 role 一方找必胜落子,在 maxMin 之前调用
+func (g *Gomoku) killWin(role int) (x, y int, ok bool) {
+	p, ok := g.killSearch(role, min(killTime, g.moveTime/4))
+	return p[0], p[1], ok
+}
+
+// 对方有算杀时,从 points 中选出 role 落子后能化解的点,超时的话只返回已经确认的点,一个都没有时返回原来的 points
+func (g *Gomoku) killDefend(points [][]int, role int) [][]int {
+	opp, limit := opponent(role), min(killTime, g.moveTime/4)
+	if _, ok := g.killSearch(opp, limit); !ok {
+		return points
+	}
+
+	g.killBudget(limit) // 逐个检查候选点另外计时
+	var safe [][]int
+	for _, p := range points {
+		g.put(p[0], p[1], role)
+		_, lose := g.kill(opp, vcfDepth, true)
+		if !lose && !g.killStop {
+			_, lose = g.kill(opp, vctDepth, false)
+		}
+		g.remove(p[0], p[1])
+		if g.killStop {
+			break
+		}
+		if !lose {
+			safe = append(safe, p)
+		}
+	}
+	if len(safe) == 0 {
+		return points
+	}
+	return safe
+}
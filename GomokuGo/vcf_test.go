@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 按行摆放棋子,X 为黑棋(先手方),O 为白棋,每行从左到右是 x,行号是 y,没写出的行都是空的
+func putRows(g *Gomoku, rows ...string) {
+	for y, r := range rows {
+		for x, c := range strings.Fields(r) {
+			switch c {
+			case "X":
+				g.put(x, y, g.black)
+			case "O":
+				g.put(x, y, opponent(g.black))
+			}
+		}
+	}
+}
+
+func TestKill(t *testing.T) {
+	tests := []struct {
+		name string
+		rule int
+		rows []string
+		// 进攻方是否白棋,以及 VCF 和 VCT 找到的第一步,nil 表示没有必胜
+		white    bool
+		vcf, vct *[2]int
+	}{
+		{
+			// 先冲四逼对方挡在 6,2,之后在斜线和竖线上连续冲四取胜,只有这一个第一步
+			name: "vcf",
+			rows: []string{
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". O X X X . . . .",
+				". . . . . . X . .",
+				". . . . . . . X .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . X",
+				". . . . . . . . X",
+				". . . . . . . . O",
+			},
+			vcf: &[2]int{5, 2},
+			vct: &[2]int{5, 2},
+		},
+		{
+			// 没有冲四,在两个活二的交点形成三三
+			name: "vct",
+			rows: []string{
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . X O",
+				". . . . . . . X .",
+				". . . . . X X . .",
+				". . . . . O . . O",
+				". . . . . . . O .",
+			},
+			vct: &[2]int{7, 7},
+		},
+		{
+			name: "none",
+			rows: []string{
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . . . .",
+				". . . . . . X O .",
+				". . . . . . O X .",
+				". . . . . X . . .",
+			},
+		},
+		{
+			// 白棋冲四,黑棋唯一的防守点 7,3 是三三禁手
+			name:  "renju forbidden defence",
+			rule:  ruleRenju,
+			white: true,
+			rows: []string{
+				". . . . . . . . . .",
+				". . . . . . . . . .",
+				". . . . . . . . . .",
+				". . X O O O . . . .",
+				". . . . . . . X X .",
+				". . . . . . . X . X",
+			},
+			vcf: &[2]int{6, 3},
+			vct: &[2]int{6, 3},
+		},
+		{
+			// 同样的局面没有禁手时黑棋可以挡住
+			name:  "freestyle same defence",
+			white: true,
+			rows: []string{
+				". . . . . . . . . .",
+				". . . . . . . . . .",
+				". . . . . . . . . .",
+				". . X O O O . . . .",
+				". . . . . . . X X .",
+				". . . . . . . X . X",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGomoku(t, tt.rule)
+			putRows(g, tt.rows...)
+			role := g.black
+			if tt.white {
+				role = opponent(g.black)
+			}
+
+			for _, c := range []struct {
+				fourOnly bool
+				depth    int
+				want     *[2]int
+			}{{true, vcfDepth, tt.vcf}, {false, vctDepth, tt.vct}} {
+				g.killNodes, g.killStop = 0, false
+				g.killBudget(10 * time.Second)
+				p, ok := g.kill(role, c.depth, c.fourOnly)
+				switch {
+				case g.killStop:
+					t.Fatalf("fourOnly %v: timeout", c.fourOnly)
+				case c.want == nil && ok:
+					t.Errorf("fourOnly %v: unexpected win at %v", c.fourOnly, p)
+				case c.want != nil && (!ok || p != *c.want):
+					t.Errorf("fourOnly %v: got %v %v, want %v", c.fourOnly, p, ok, *c.want)
+				}
+			}
+
+			// killSearch 先找 VCF 再找 VCT
+			want := tt.vcf
+			if want == nil {
+				want = tt.vct
+			}
+			p, ok := g.killSearch(role, 10*time.Second)
+			if ok != (want != nil) || ok && p != *want {
+				t.Errorf("killSearch: got %v %v, want %v", p, ok, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// Package locale 选择游戏的界面语言,各个游戏自己维护翻译文字
+package locale
+
+import (
+	"os"
+	"strings"
+)
+
+// Pick 返回界面语言 "zh" 或 "en",name为空时根据系统区域设置选择,只支持英文和简体中文
+func Pick(name string) string {
+	if name == "" {
+		for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if name = os.Getenv(k); name != "" {
+				break
+			}
+		}
+	}
+
+	if strings.HasPrefix(strings.ToLower(name), "zh") {
+		return "zh"
+	}
+	return "en"
+}
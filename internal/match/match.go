@@ -0,0 +1,52 @@
+// Package match 统计自我对弈的结果,用来检查各个游戏棋力等级的等级分
+package match
+
+import (
+	"fmt"
+	"math"
+)
+
+// Score 一方的胜,和,负局数
+type Score struct {
+	Win, Draw, Loss int
+}
+
+// Add 记录一局的结果,result 大于0为胜,等于0为和,小于0为负
+func (s *Score) Add(result int) {
+	switch {
+	case result > 0:
+		s.Win++
+	case result < 0:
+		s.Loss++
+	default:
+		s.Draw++
+	}
+}
+
+// Rate 得分率,和棋算半局
+func (s Score) Rate() float64 {
+	n := s.Win + s.Draw + s.Loss
+	if n == 0 {
+		return 0.5
+	}
+	return (float64(s.Win) + float64(s.Draw)/2) / float64(n)
+}
+
+// EloDiff 得分率对应的等级分差,全胜或者全负时没有上限,返回 ok 为false
+func (s Score) EloDiff() (diff float64, ok bool) {
+	p := s.Rate()
+	if p <= 0 || p >= 1 {
+		return 0, false
+	}
+	return -400 * math.Log10(1/p-1), true
+}
+
+func (s Score) String() string {
+	d := "unbounded"
+	if v, ok := s.EloDiff(); ok {
+		if d = fmt.Sprintf("%+.0f", v); d == "-0" || d == "+0" {
+			d = "0"
+		}
+	}
+	return fmt.Sprintf("+%d =%d -%d, score %.0f%%, elo diff %s", s.Win, s.Draw, s.Loss, s.Rate()*100, d)
+}
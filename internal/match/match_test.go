@@ -0,0 +1,25 @@
+package match
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	var s Score
+	for _, r := range []int{1, 1, 1, 0, -1} {
+		s.Add(r)
+	}
+	if s.Rate() != 0.7 {
+		t.Fatalf("rate %v, want 0.7", s.Rate())
+	}
+	if d, ok := s.EloDiff(); !ok || d < 147 || d > 148 {
+		t.Fatalf("elo diff %v", d)
+	}
+	if got, want := s.String(), "+3 =1 -1, score 70%, elo diff +147"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := (Score{Win: 1, Loss: 1}).String(); got != "+1 =0 -1, score 50%, elo diff 0" {
+		t.Fatalf("even score %q", got)
+	}
+	if _, ok := (Score{Win: 2}).EloDiff(); ok {
+		t.Fatal("elo diff of a clean sweep should be unbounded")
+	}
+}
@@ -0,0 +1,25 @@
+// Package ui 是各个游戏窗口共用的绘制代码
+package ui
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/bitmapfont/v3"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// Face 内嵌的12px点阵字体,包含简体中文字形,替代只支持ASCII的 ebitenutil.DebugPrintAt
+var Face = text.NewGoXFace(bitmapfont.FaceSC)
+
+// DrawText 在x,y位置画白色文字,并带有1像素黑色阴影,确保在任何背景上都能看清
+func DrawText(dst *ebiten.Image, s string, x, y int) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(x+1), float64(y+1))
+	op.ColorScale.ScaleWithColor(color.Black)
+	text.Draw(dst, s, Face, op)
+
+	op.GeoM.Translate(-1, -1)
+	op.ColorScale.Reset()
+	text.Draw(dst, s, Face, op)
+}
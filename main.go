@@ -60,7 +60,8 @@ func build() error {
 
 	for _, d := range de {
 		name := d.Name()
-		if d.IsDir() && !strings.HasPrefix(name, ".") {
+		// internal 中是各个游戏共用的包,不是游戏
+		if d.IsDir() && !strings.HasPrefix(name, ".") && name != "internal" {
 			cmd := exec.Command("go", "build", "-C", name, "-trimpath", "-ldflags", ldSW, "-o", "..")
 			cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
 			info, err = cmd.CombinedOutput()
@@ -0,0 +1,33 @@
+package main
+
+import "github.com/jan-bar/LittleGame/internal/locale"
+
+// 界面文字编号,对应 langText 中的翻译
+const (
+	msgTitle  = iota // 窗口标题
+	msgInput         // 雷区信息及输入提示,依次为高,宽,雷数,种子,必须以'>'结尾
+	msgResume        // 询问是否继续上次的雷区
+	msgLength        // 文字总数
+)
+
+var (
+	langText = map[string]*[msgLength]string{
+		"en": {
+			msgTitle:  "Mine Sweeping",
+			msgInput:  "H:%d,W:%d,M:%d,S:%d >",
+			msgResume: "Resume the last game? Y: Yes  N: No",
+		},
+		"zh": {
+			msgTitle:  "扫雷",
+			msgInput:  "高:%d,宽:%d,雷:%d,种子:%d >",
+			msgResume: "继续上次的雷区? Y键继续 N键重新开始",
+		},
+	}
+	// 当前使用的语言
+	lang = langText["en"]
+)
+
+// 设置界面语言,name为空时根据系统区域设置选择
+func setLang(name string) {
+	lang = langText[locale.Pick(name)]
+}
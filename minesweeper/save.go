@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// 每5秒检查一次是否需要保存,计时器一直在变,检查太频繁会不停地写文件
+const saveInterval = 5 * 60
+
+// 自动保存的雷区,关闭窗口时和游戏中定时保存,下次启动时可以选择继续
+// 相同的宽高,雷数和种子生成相同的雷区,所以只需要保存格子的状态
+type savedGame struct {
+	H       int           `json:"h"`
+	W       int           `json:"w"`
+	Mines   int           `json:"mines"`
+	Seed    int64         `json:"seed"`
+	Elapsed time.Duration `json:"elapsed"` // 已用时间
+	// 每行一个字符串,'.'为没打开,'o'为已打开,'f'为插旗
+	Rows []string `json:"rows"`
+}
+
+// 保存游戏的文件,在用户配置目录下,浏览器中取不到目录时不保存
+func savePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "LittleGame", "minesweeper.json")
+}
+
+// 读取上次没有完成的雷区,没有或者格式不对时返回nil
+func loadSaved() *savedGame {
+	name := savePath()
+	if name == "" {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("read saved game: %v", err)
+		}
+		return nil
+	}
+
+	s := new(savedGame)
+	if err = json.Unmarshal(data, s); err == nil {
+		err = s.check()
+	}
+	if err != nil {
+		log.Printf("read saved game %s: %v", name, err)
+		return nil
+	}
+	return s
+}
+
+// 和输入自定义雷区时的限制相同
+func (s *savedGame) check() error {
+	if s.H < 9 || s.H > 45 || s.W < 9 || s.W > 45 || s.Mines < 10 || s.Mines > (s.H-1)*(s.W-1) || s.Seed <= 0 {
+		return fmt.Errorf("invalid size %dx%d with %d mines, seed %d", s.H, s.W, s.Mines, s.Seed)
+	}
+	if len(s.Rows) != s.H {
+		return fmt.Errorf("want %d rows, got %d", s.H, len(s.Rows))
+	}
+	for i, row := range s.Rows {
+		if len(row) != s.W || strings.Trim(row, ".of") != "" {
+			return fmt.Errorf("invalid row %d %q", i+1, row)
+		}
+	}
+	return nil
+}
+
+// 当前雷区的保存内容,还没开始或者已经结束时返回空
+func (m *mine) saveData() string {
+	if m.playing != 0 || m.timeStart.IsZero() {
+		return ""
+	}
+
+	s := savedGame{H: m.h, W: m.w, Mines: m.mineCnt, Seed: m.seed, Elapsed: time.Since(m.timeStart)}
+	row := make([]byte, m.w)
+	for i := 0; i < m.h; i++ {
+		for j := 0; j < m.w; j++ {
+			switch m.data[i][j].state {
+			case -1:
+				row[j] = 'o'
+			case 2:
+				row[j] = 'f'
+			default: // 按住鼠标时的状态不保存
+				row[j] = '.'
+			}
+		}
+		s.Rows = append(s.Rows, string(row))
+	}
+	data, _ := json.Marshal(&s)
+	return string(data)
+}
+
+// 内容有变化时写入文件,没有需要保存的雷区时删除文件
+func (m *mine) autoSave() {
+	if data := m.saveData(); data != m.saved {
+		m.saved = data
+		writeSaved(data)
+	}
+}
+
+// 写入保存的雷区,data 为空时删除文件,失败时只记录日志
+func writeSaved(data string) {
+	name := savePath()
+	if name == "" {
+		return
+	}
+	if data == "" {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("remove saved game: %v", err)
+		}
+		return
+	}
+
+	// 先写临时文件再改名,写到一半退出时上次保存的文件还是完整的
+	err := os.MkdirAll(filepath.Dir(name), 0o755)
+	if err == nil {
+		if err = os.WriteFile(name+".tmp", []byte(data), 0o644); err == nil {
+			err = os.Rename(name+".tmp", name)
+		}
+	}
+	if err != nil {
+		log.Printf("save game: %v", err)
+	}
+}
+
+// 定时保存雷区,关闭窗口时保存后返回 ebiten.Termination 结束游戏
+func (m *mine) updateSave() error {
+	if ebiten.IsWindowBeingClosed() {
+		if m.resume == nil {
+			m.autoSave() // 还没选择是否继续时,保留上次的雷区
+		}
+		return ebiten.Termination
+	}
+	if m.saveTick++; m.resume == nil && m.saveTick >= saveInterval {
+		m.saveTick = 0
+		m.autoSave()
+	}
+	return nil
+}
+
+// 启动时询问是否继续上次的雷区,Y键继续,N键开始新的雷区
+func (m *mine) updateResume() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyY):
+		s := m.resume
+		m.resume = nil
+		m.h, m.w, m.mineCnt, m.seed = s.H, s.W, s.Mines, s.Seed
+		m.initData()
+		for i, row := range s.Rows {
+			for j := range row {
+				switch row[j] {
+				case 'o':
+					m.data[i][j].state = -1
+				case 'f':
+					m.data[i][j].state = 2
+				}
+			}
+		}
+		// 计时器从上次保存时的用时接着走
+		m.timeStart = time.Now().Add(-s.Elapsed)
+		m.timeCnt = min(int(s.Elapsed/time.Second), 999)
+		log.Printf("resumed a saved game after %v", s.Elapsed.Round(time.Second))
+	case inpututil.IsKeyJustPressed(ebiten.KeyN):
+		m.resume = nil
+		m.text = fmt.Sprintf(lang[msgInput], m.h, m.w, m.mineCnt, m.seed)
+		writeSaved("") // 不再继续,删除保存的雷区
+	}
+}
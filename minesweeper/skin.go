@@ -0,0 +1,57 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// 皮肤包中图片的宽高,竖着排列的多张小图片必须和内置图片的张数和大小一致,窗口图标不限制大小
+var skinSizes = map[string]image.Point{
+	"mine.png": {X: gridHW, Y: gridHW * 16}, // 16张格子图片
+	"num.png":  {X: 13, Y: 23 * 12},         // 12张计数器数字
+	"face.png": {X: 24, Y: 24 * 5},          // 5张笑脸
+}
+
+// 打开皮肤包,可以是目录或者zip文件,返回的 io.Closer 在读取完图片后关闭
+func openSkin(name string) (fs.FS, io.Closer, error) {
+	st, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("skin: %w", err)
+	}
+	if st.IsDir() {
+		return os.DirFS(name), io.NopCloser(nil), nil
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("skin %s: not a directory or zip file: %w", name, err)
+	}
+	return zr, zr, nil
+}
+
+// 打开图片文件,皮肤包中有同名文件时优先使用,skin 为nil时只用内置图片
+func openImage(skin fs.FS, f *zip.File) (rc io.ReadCloser, fromSkin bool, err error) {
+	if skin != nil {
+		sf, err := skin.Open(f.Name)
+		if err == nil {
+			return sf, true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+	rc, err = f.Open()
+	return
+}
+
+func checkSkinImage(name string, b image.Rectangle) error {
+	if want, ok := skinSizes[name]; ok && b.Size() != want {
+		return fmt.Errorf("image is %dx%d, want %dx%d", b.Dx(), b.Dy(), want.X, want.Y)
+	}
+	return nil
+}